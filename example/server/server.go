@@ -15,7 +15,10 @@ func NewServerService(
 ) (*ServerService, error) {
 
 	svr := grpcserver.NewGrpcServer(grpcserver.WithLogger(log))
-	httpGateway, err := grpchttpgatewayserver.NewHTTPGatewayServer(svr, log, httpPort)
+	// SayHelloStream is bidi, browsers reach it over websockets
+	httpGateway, err := grpchttpgatewayserver.NewHTTPGatewayServer(svr, log, httpPort,
+		grpchttpgatewayserver.WithWebSocketBridge(grpchttpgatewayserver.WebSocketConfig{}),
+	)
 	if err != nil {
 		return nil, err
 	}
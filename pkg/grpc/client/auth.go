@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+// appendBearerToOutgoingContext forwards the token of the incoming call, unless
+// the caller sets authorization explicitly
+func appendBearerToOutgoingContext(ctx context.Context) context.Context {
+	token, found := runtime.KeyInfo(ctx)
+	if !found || len(token) == 0 {
+		return ctx
+	}
+	if md, exists := metadata.FromOutgoingContext(ctx); exists && len(md.Get("authorization")) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// BearerUnaryClientInterceptor forwards the bearer token of the incoming call
+// (set by authnmiddleware) to the callee
+func BearerUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(appendBearerToOutgoingContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// BearerStreamClientInterceptor forwards the bearer token of the incoming call
+// (set by authnmiddleware) to the callee
+func BearerStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(appendBearerToOutgoingContext(ctx), desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+type ClientConnConfigurer interface {
+	apply(o *clientConnConfig)
+}
+
+type clientConnConfig struct {
+	logger             logger.Logger
+	transportCreds     credentials.TransportCredentials
+	retryPolicies      []RetryPolicy
+	forwardBearer      bool
+	dialOpts           []grpc.DialOption
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+}
+
+type clientLogger struct {
+	logger logger.Logger
+}
+
+var (
+	_ ClientConnConfigurer = clientLogger{}
+)
+
+func (c clientLogger) apply(o *clientConnConfig) {
+	o.logger = c.logger
+}
+
+// WithLogger logs calls via grpc_zap, calls are not logged without it
+func WithLogger(logger logger.Logger) clientLogger {
+	return clientLogger{logger: logger}
+}
+
+type transportCredentials struct {
+	creds credentials.TransportCredentials
+}
+
+var (
+	_ ClientConnConfigurer = transportCredentials{}
+)
+
+func (t transportCredentials) apply(o *clientConnConfig) {
+	o.transportCreds = t.creds
+}
+
+// WithTransportCredentials defaults to insecure credentials
+func WithTransportCredentials(creds credentials.TransportCredentials) transportCredentials {
+	return transportCredentials{creds: creds}
+}
+
+type retryPolicies struct {
+	policies []RetryPolicy
+}
+
+var (
+	_ ClientConnConfigurer = retryPolicies{}
+)
+
+func (r retryPolicies) apply(o *clientConnConfig) {
+	o.retryPolicies = append(o.retryPolicies, r.policies...)
+}
+
+// WithRetryPolicy retries calls as configured by policies, via grpc service config
+func WithRetryPolicy(policies ...RetryPolicy) retryPolicies {
+	return retryPolicies{policies: policies}
+}
+
+type bearerForwarding struct{}
+
+var (
+	_ ClientConnConfigurer = bearerForwarding{}
+)
+
+func (b bearerForwarding) apply(o *clientConnConfig) {
+	o.forwardBearer = true
+}
+
+// WithBearerForwarding sends the token of the incoming call (runtime.KeyInfo)
+// to the callee, it should only be used to call trusted services
+func WithBearerForwarding() bearerForwarding {
+	return bearerForwarding{}
+}
+
+type dialOption struct {
+	dialOpts []grpc.DialOption
+}
+
+var (
+	_ ClientConnConfigurer = dialOption{}
+)
+
+func (d dialOption) apply(o *clientConnConfig) {
+	o.dialOpts = append(o.dialOpts, d.dialOpts...)
+}
+
+func WithDialOption(dialOpts ...grpc.DialOption) dialOption {
+	return dialOption{dialOpts: dialOpts}
+}
+
+type interceptorConfigure struct {
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+}
+
+var (
+	_ ClientConnConfigurer = interceptorConfigure{}
+)
+
+func (i interceptorConfigure) apply(o *clientConnConfig) {
+	o.unaryInterceptors = append(o.unaryInterceptors, i.unaryInterceptors...)
+	o.streamInterceptors = append(o.streamInterceptors, i.streamInterceptors...)
+}
+
+// WithInterceptor adds interceptors, which run after the built-in ones
+func WithInterceptor(unary []grpc.UnaryClientInterceptor, stream []grpc.StreamClientInterceptor) interceptorConfigure {
+	return interceptorConfigure{
+		unaryInterceptors:  unary,
+		streamInterceptors: stream,
+	}
+}
+
+func newConfig(cfgs ...ClientConnConfigurer) *clientConnConfig {
+	o := &clientConnConfig{
+		transportCreds: insecure.NewCredentials(),
+	}
+	for _, cfg := range cfgs {
+		cfg.apply(o)
+	}
+	return o
+}
+
+// NewClientConn returns a client conn to target, with interceptors mirroring
+// the server middlewares: request id and bearer forwarding, metrics, logging
+// and otel tracing.
+func NewClientConn(target string, cfgs ...ClientConnConfigurer) (*grpc.ClientConn, error) {
+	config := newConfig(cfgs...)
+
+	unaryInterceptors := []grpc.UnaryClientInterceptor{
+		RequestIdUnaryClientInterceptor(),
+	}
+	streamInterceptors := []grpc.StreamClientInterceptor{
+		RequestIdStreamClientInterceptor(),
+	}
+	if config.forwardBearer {
+		unaryInterceptors = append(unaryInterceptors, BearerUnaryClientInterceptor())
+		streamInterceptors = append(streamInterceptors, BearerStreamClientInterceptor())
+	}
+	unaryInterceptors = append(unaryInterceptors, MetricsUnaryClientInterceptor())
+	streamInterceptors = append(streamInterceptors, MetricsStreamClientInterceptor())
+	if config.logger != nil {
+		unaryInterceptors = append(unaryInterceptors, LoggerUnaryClientInterceptor(config.logger))
+		streamInterceptors = append(streamInterceptors, LoggerStreamClientInterceptor(config.logger))
+	}
+	unaryInterceptors = append(unaryInterceptors, config.unaryInterceptors...)
+	streamInterceptors = append(streamInterceptors, config.streamInterceptors...)
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(config.transportCreds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(unaryInterceptors...),
+		grpc.WithChainStreamInterceptor(streamInterceptors...),
+	}
+	if len(config.retryPolicies) > 0 {
+		serviceConfig, err := newServiceConfig(config.retryPolicies)
+		if err != nil {
+			return nil, err
+		}
+		dialOpts = append(dialOpts, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+	dialOpts = append(dialOpts, config.dialOpts...)
+	return grpc.NewClient(target, dialOpts...)
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+func TestNewClientConn(t *testing.T) {
+	var attempts int
+	var gotMD metadata.MD
+	failFirst := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		attempts++
+		gotMD, _ = metadata.FromIncomingContext(ctx)
+		if attempts == 1 {
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+		return handler(ctx, req)
+	}
+
+	li := bufconn.Listen(1024 * 1024)
+	svr := grpc.NewServer(grpc.UnaryInterceptor(failFirst))
+	healthpb.RegisterHealthServer(svr, health.NewServer())
+	go svr.Serve(li)
+	defer svr.Stop()
+
+	conn, err := NewClientConn("passthrough:///bufnet",
+		WithLogger(logger.NewLogger(false)),
+		WithBearerForwarding(),
+		WithRetryPolicy(RetryPolicy{
+			Services:          []string{"grpc.health.v1.Health"},
+			MaxAttempts:       2,
+			InitialBackoff:    10 * time.Millisecond,
+			MaxBackoff:        10 * time.Millisecond,
+			BackoffMultiplier: 1,
+			RetryableCodes:    []codes.Code{codes.Unavailable},
+		}),
+		WithDialOption(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return li.DialContext(ctx)
+		})),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ctx := runtime.WithGivenRequestId(runtime.WithKeyInfo(context.Background(), "token"), "req-1")
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, []string{"Bearer token"}, gotMD.Get("authorization"))
+	assert.Equal(t, []string{"req-1"}, gotMD.Get("x-request-id"))
+}
+
+func TestInvalidRetryPolicy(t *testing.T) {
+	_, err := NewClientConn("passthrough:///bufnet", WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	assert.Error(t, err)
+	_, err = NewClientConn("passthrough:///bufnet", WithRetryPolicy(RetryPolicy{Methods: []string{"nomethod"}, MaxAttempts: 2, InitialBackoff: time.Second, MaxBackoff: time.Second, BackoffMultiplier: 1, RetryableCodes: []codes.Code{codes.Unavailable}}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+// codeToLevel logs successful calls at debug, as the server logger middleware
+func codeToLevel(code codes.Code) zapcore.Level {
+	if code == codes.OK {
+		return zap.DebugLevel
+	}
+	return grpc_zap.DefaultClientCodeToLevel(code)
+}
+
+func LoggerUnaryClientInterceptor(l logger.Logger) grpc.UnaryClientInterceptor {
+	return grpc_zap.UnaryClientInterceptor(logger.GetUnderlyingZapLoggerOrDie(l), grpc_zap.WithLevels(codeToLevel))
+}
+
+func LoggerStreamClientInterceptor(l logger.Logger) grpc.StreamClientInterceptor {
+	return grpc_zap.StreamClientInterceptor(logger.GetUnderlyingZapLoggerOrDie(l), grpc_zap.WithLevels(codeToLevel))
+}
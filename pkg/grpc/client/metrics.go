@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sdinsure/agent/pkg/metrics"
+)
+
+var clientNamespace = metrics.NewTypeNamespace("rpc_client")
+var subsystem = metrics.NewTypeSubsystem("requests")
+
+var (
+	metricClientReqDur = metrics.NewTimeDurationHistogramVec(
+		clientNamespace,
+		subsystem,
+		metrics.NewTypeMetricName("duration_ms"),
+		"method",
+	)
+	metricClientReqCodeTotal = metrics.NewCounterVec(
+		clientNamespace,
+		subsystem,
+		metrics.NewTypeMetricName("code_total"),
+		"method", "rpccode",
+	)
+)
+
+func MetricsUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		startTime := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		metricClientReqDur.Observe(ctx, time.Since(startTime), method)
+		metricClientReqCodeTotal.Inc(ctx, method, strconv.Itoa(int(status.Code(err))))
+		return err
+	}
+}
+
+// MetricsStreamClientInterceptor records streams when they end, which is when
+// RecvMsg returns an error (io.EOF on success), when the response of a client
+// streaming call is received, or when ctx is done
+func MetricsStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		startTime := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			metricClientReqCodeTotal.Inc(ctx, method, strconv.Itoa(int(status.Code(err))))
+			return nil, err
+		}
+		m := &metricClientStream{ClientStream: stream, ctx: ctx, desc: desc, method: method, startTime: startTime}
+		// streams abandoned by the caller never see RecvMsg fail
+		m.stop = context.AfterFunc(ctx, func() {
+			m.record(status.FromContextError(ctx.Err()).Code())
+		})
+		return m, nil
+	}
+}
+
+type metricClientStream struct {
+	grpc.ClientStream
+
+	ctx       context.Context
+	desc      *grpc.StreamDesc
+	method    string
+	startTime time.Time
+	once      sync.Once
+	stop      func() bool
+}
+
+func (m *metricClientStream) RecvMsg(msg interface{}) error {
+	err := m.ClientStream.RecvMsg(msg)
+	if err != nil {
+		code := status.Code(err)
+		if err == io.EOF {
+			code = codes.OK
+		}
+		m.stop()
+		m.record(code)
+	} else if !m.desc.ServerStreams {
+		// client streaming calls receive a single response
+		m.stop()
+		m.record(codes.OK)
+	}
+	return err
+}
+
+func (m *metricClientStream) record(code codes.Code) {
+	m.once.Do(func() {
+		metricClientReqDur.Observe(m.ctx, time.Since(m.startTime), m.method)
+		metricClientReqCodeTotal.Inc(m.ctx, m.method, strconv.Itoa(int(code)))
+	})
+}
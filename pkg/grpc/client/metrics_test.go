@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type fakeClientStream struct {
+	grpc.ClientStream
+	responses int
+}
+
+func (f *fakeClientStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (f *fakeClientStream) CloseSend() error {
+	return nil
+}
+
+func (f *fakeClientStream) RecvMsg(m interface{}) error {
+	if f.responses == 0 {
+		return io.EOF
+	}
+	f.responses--
+	return nil
+}
+
+// gatherCounts returns sample counts of metrics with method label of method, by
+// metric name and rpccode
+func gatherCounts(t *testing.T, method string) map[string]float64 {
+	families, err := prom.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] != method {
+				continue
+			}
+			switch {
+			case m.GetCounter() != nil:
+				values[family.GetName()+"_"+labels["rpccode"]] = m.GetCounter().GetValue()
+			case m.GetHistogram() != nil:
+				values[family.GetName()] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func TestMetricsStreamClientInterceptor(t *testing.T) {
+	newStream := func(ctx context.Context, desc *grpc.StreamDesc, method string, responses int) grpc.ClientStream {
+		stream, err := MetricsStreamClientInterceptor()(ctx, desc, nil, method, func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{responses: responses}, nil
+		})
+		assert.NoError(t, err)
+		return stream
+	}
+
+	// server streaming, recorded at io.EOF
+	stream := newStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, "/test.Service/List", 2)
+	for stream.RecvMsg(nil) == nil {
+	}
+	values := gatherCounts(t, "/test.Service/List")
+	assert.EqualValues(t, 1, values["rpc_client_requests_duration_ms"])
+	assert.EqualValues(t, 1, values["rpc_client_requests_code_total_0"])
+
+	// client streaming, recorded at the response of CloseAndRecv
+	stream = newStream(context.Background(), &grpc.StreamDesc{ClientStreams: true}, "/test.Service/Upload", 1)
+	assert.NoError(t, stream.SendMsg(nil))
+	assert.NoError(t, stream.CloseSend())
+	assert.NoError(t, stream.RecvMsg(nil))
+	values = gatherCounts(t, "/test.Service/Upload")
+	assert.EqualValues(t, 1, values["rpc_client_requests_duration_ms"])
+	assert.EqualValues(t, 1, values["rpc_client_requests_code_total_0"])
+
+	// abandoned by the caller
+	ctx, cancel := context.WithCancel(context.Background())
+	stream = newStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/test.Service/Watch", 1)
+	assert.NoError(t, stream.RecvMsg(nil))
+	cancel()
+	assert.Eventually(t, func() bool {
+		return gatherCounts(t, "/test.Service/Watch")["rpc_client_requests_code_total_1"] == 1
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+// RequestIdUnaryClientInterceptor forwards the request id in context to the
+// callee as x-request-id metadata
+func RequestIdUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(runtime.AppendRequestIdToOutgoingContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// RequestIdStreamClientInterceptor forwards the request id in context to the
+// callee as x-request-id metadata
+func RequestIdStreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(runtime.AppendRequestIdToOutgoingContext(ctx), desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy retries calls of Services (or Methods) failing with
+// RetryableCodes. it is applied by grpc as service config, see
+// https://github.com/grpc/grpc/blob/master/doc/connection-backoff.md
+type RetryPolicy struct {
+	// Services are full service names, e.g. "example.api.HelloService".
+	// Services and Methods both empty apply the policy to all methods.
+	Services []string
+	// Methods are full method names, e.g. "/example.api.HelloService/SayHello"
+	Methods []string
+
+	// MaxAttempts includes the first attempt, grpc caps it at 5
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+	RetryableCodes    []codes.Code
+}
+
+// DefaultRetryPolicy retries Unavailable, which is safe for all methods as
+// the call was not processed
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:       3,
+	InitialBackoff:    100 * time.Millisecond,
+	MaxBackoff:        time.Second,
+	BackoffMultiplier: 2,
+	RetryableCodes:    []codes.Code{codes.Unavailable},
+}
+
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+type methodConfig struct {
+	Name        []methodName      `json:"name"`
+	RetryPolicy retryPolicyConfig `json:"retryPolicy"`
+}
+
+type methodName struct {
+	Service string `json:"service,omitempty"`
+	Method  string `json:"method,omitempty"`
+}
+
+type retryPolicyConfig struct {
+	MaxAttempts          int          `json:"maxAttempts"`
+	InitialBackoff       string       `json:"initialBackoff"`
+	MaxBackoff           string       `json:"maxBackoff"`
+	BackoffMultiplier    float64      `json:"backoffMultiplier"`
+	RetryableStatusCodes []codes.Code `json:"retryableStatusCodes"`
+}
+
+func newServiceConfig(policies []RetryPolicy) (string, error) {
+	config := serviceConfig{}
+	for _, policy := range policies {
+		mc, err := newMethodConfig(policy)
+		if err != nil {
+			return "", err
+		}
+		config.MethodConfig = append(config.MethodConfig, mc)
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func newMethodConfig(policy RetryPolicy) (methodConfig, error) {
+	if policy.MaxAttempts < 2 {
+		return methodConfig{}, errors.New("client: retry policy requires at least 2 attempts")
+	}
+	if policy.InitialBackoff <= 0 || policy.MaxBackoff <= 0 || policy.BackoffMultiplier <= 0 {
+		return methodConfig{}, errors.New("client: retry policy requires positive backoff")
+	}
+	if len(policy.RetryableCodes) == 0 {
+		return methodConfig{}, errors.New("client: retry policy requires retryable codes")
+	}
+
+	mc := methodConfig{
+		RetryPolicy: retryPolicyConfig{
+			MaxAttempts:       policy.MaxAttempts,
+			InitialBackoff:    durationString(policy.InitialBackoff),
+			MaxBackoff:        durationString(policy.MaxBackoff),
+			BackoffMultiplier: policy.BackoffMultiplier,
+			// codes are marshaled as numbers, which grpc accepts as well as names
+			RetryableStatusCodes: policy.RetryableCodes,
+		},
+	}
+	for _, service := range policy.Services {
+		mc.Name = append(mc.Name, methodName{Service: service})
+	}
+	for _, method := range policy.Methods {
+		service, name, found := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+		if !found {
+			return methodConfig{}, fmt.Errorf("client: invalid method name %q", method)
+		}
+		mc.Name = append(mc.Name, methodName{Service: service, Method: name})
+	}
+	if len(mc.Name) == 0 {
+		// an empty name matches all methods
+		mc.Name = []methodName{{}}
+	}
+	return mc, nil
+}
+
+func durationString(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
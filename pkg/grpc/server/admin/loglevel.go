@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	sderrors "github.com/sdinsure/agent/pkg/errors"
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+// LogLevelRequest changes the global level, or the level of Component if set.
+// TTL (e.g. "10m") reverts the change after the duration. an empty Level
+// makes Component follow the global level again.
+type LogLevelRequest struct {
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+	TTL       string `json:"ttl,omitempty"`
+}
+
+type LogLevelResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+func NewLogLevelAdmin(levels *logger.LevelController) *LogLevelAdmin {
+	return &LogLevelAdmin{levels: levels}
+}
+
+// LogLevelAdmin exposes a LevelController via http (ServeHTTP) and grpc
+// (LogLevelServiceDesc). it does not authenticate callers, so it should only
+// be served on an internal port or behind an authenticated route.
+type LogLevelAdmin struct {
+	levels *logger.LevelController
+}
+
+func (l *LogLevelAdmin) Get() *LogLevelResponse {
+	global, components := l.levels.Levels()
+	resp := &LogLevelResponse{
+		Level:      global.String(),
+		Components: make(map[string]string),
+	}
+	for name, level := range components {
+		resp.Components[name] = level.String()
+	}
+	return resp
+}
+
+func (l *LogLevelAdmin) Set(req *LogLevelRequest) (*LogLevelResponse, error) {
+	var ttl time.Duration
+	if len(req.TTL) > 0 {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil {
+			return nil, sderrors.NewBadParamsError(fmt.Errorf("loglevel: invalid ttl, err:%w", err))
+		}
+	}
+	if len(req.Level) == 0 {
+		if len(req.Component) == 0 {
+			return nil, sderrors.NewBadParamsError(fmt.Errorf("loglevel: level is required"))
+		}
+		l.levels.UnsetComponentLevel(req.Component)
+		return l.Get(), nil
+	}
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil {
+		return nil, sderrors.NewBadParamsError(fmt.Errorf("loglevel: invalid level, err:%w", err))
+	}
+	if len(req.Component) > 0 {
+		l.levels.SetComponentLevel(req.Component, level, ttl)
+	} else {
+		l.levels.SetLevel(level, ttl)
+	}
+	return l.Get(), nil
+}
+
+// ServeHTTP returns the levels on GET, and changes them on PUT/POST with a
+// json LogLevelRequest body
+func (l *LogLevelAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, l.Get())
+	case http.MethodPut, http.MethodPost:
+		req := &LogLevelRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		resp, err := l.Set(req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// LogLevelServiceServer is the grpc interface of LogLevelAdmin. messages are
+// google.protobuf.Struct carrying the json form of LogLevelRequest and
+// LogLevelResponse, so no generated code is required by callers.
+type LogLevelServiceServer interface {
+	GetLogLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	SetLogLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+var (
+	_ LogLevelServiceServer = &LogLevelAdmin{}
+)
+
+func (l *LogLevelAdmin) GetLogLevel(ctx context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	return toStruct(l.Get())
+}
+
+func (l *LogLevelAdmin) SetLogLevel(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	req := &LogLevelRequest{}
+	if err := fromStruct(in, req); err != nil {
+		return nil, sderrors.NewBadParamsError(err)
+	}
+	resp, err := l.Set(req)
+	if err != nil {
+		return nil, err
+	}
+	return toStruct(resp)
+}
+
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := &structpb.Struct{}
+	if err := s.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func fromStruct(s *structpb.Struct, v interface{}) error {
+	b, err := s.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// LogLevelServiceDesc is registered with ServerService.RegisterService, e.g.
+//
+//	svc.RegisterService(&admin.LogLevelServiceDesc, admin.NewLogLevelAdmin(levels))
+var LogLevelServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sdinsure.admin.LogLevelService",
+	HandlerType: (*LogLevelServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLogLevel",
+			Handler:    getLogLevelHandler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    setLogLevelHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sdinsure/admin/loglevel",
+}
+
+func getLogLevelHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &structpb.Struct{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogLevelServiceServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sdinsure.admin.LogLevelService/GetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogLevelServiceServer).GetLogLevel(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func setLogLevelHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &structpb.Struct{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogLevelServiceServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sdinsure.admin.LogLevelService/SetLogLevel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogLevelServiceServer).SetLogLevel(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
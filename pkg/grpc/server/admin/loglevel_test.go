@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+func TestLogLevelHttp(t *testing.T) {
+	levels := logger.NewLevelController(logger.InfoLevel)
+	h := NewLogLevelAdmin(levels)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug","component":"authz"}`)))
+	assert.EqualValues(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"info","components":{"authz":"debug"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"verbose"}`)))
+	assert.EqualValues(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/loglevel", strings.NewReader(`{"level":"warn"}`)))
+	assert.EqualValues(t, http.StatusOK, w.Code)
+	assert.EqualValues(t, logger.WarnLevel, levels.Level())
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+type AccessLogFormat string
+
+const (
+	// AccessLogJSON writes one json AccessLogRecord per line
+	AccessLogJSON AccessLogFormat = "json"
+	// AccessLogCombined writes the apache combined log format
+	AccessLogCombined AccessLogFormat = "combined"
+)
+
+type AccessLogConfig struct {
+	Format AccessLogFormat
+	// Writer is the sink of access logs, defaults to os.Stdout. use
+	// logger.NewFileWriter for a rotated file.
+	Writer io.Writer
+	// SuccessSampleRatio in (0, 1] logs the ratio of requests with status
+	// below 400, 0 logs all of them. failed requests are always logged.
+	SuccessSampleRatio float64
+}
+
+type accessLog struct {
+	cfg AccessLogConfig
+}
+
+func (a accessLog) apply(c *HTTPGatewayServerConfig) {
+	c.accessLog = &a.cfg
+}
+
+// WithAccessLog writes an access log record per request, which replaces the
+// per request line of the gateway logger
+func WithAccessLog(cfg AccessLogConfig) accessLog {
+	return accessLog{cfg: cfg}
+}
+
+type AccessLogRecord struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Pattern   string    `json:"pattern,omitempty"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	BytesIn   int64     `json:"bytes_in"`
+	BytesOut  int64     `json:"bytes_out"`
+	LatencyMs float64   `json:"latency_ms"`
+	Remote    string    `json:"remote_addr"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	RequestId string    `json:"request_id,omitempty"`
+	TraceId   string    `json:"trace_id,omitempty"`
+}
+
+func newAccessLogger(cfg AccessLogConfig) *accessLogger {
+	if cfg.Writer == nil {
+		cfg.Writer = os.Stdout
+	}
+	if len(cfg.Format) == 0 {
+		cfg.Format = AccessLogJSON
+	}
+	return &accessLogger{cfg: cfg}
+}
+
+type accessLogger struct {
+	cfg AccessLogConfig
+
+	mu sync.Mutex
+}
+
+// withAccessLog should run after withRequestId and withRequestInfo
+func withAccessLog(cfg AccessLogConfig) HttpMiddlewareHandler {
+	a := newAccessLogger(cfg)
+	return HttpMiddlewareHandler(func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			body := &countingReader{ReadCloser: request.Body}
+			if request.Body != nil {
+				request.Body = body
+			}
+			start := time.Now()
+			m := httpsnoop.CaptureMetrics(handler, writer, request)
+
+			if m.Code < http.StatusBadRequest && !a.sampled() {
+				return
+			}
+			info := requestInfoFromContext(request.Context())
+			record := &AccessLogRecord{
+				Time:      start,
+				Method:    request.Method,
+				Path:      request.URL.Path,
+				Pattern:   info.pattern,
+				Proto:     request.Proto,
+				Status:    m.Code,
+				BytesIn:   body.n,
+				BytesOut:  m.Written,
+				LatencyMs: float64(m.Duration.Microseconds()) / 1000,
+				Remote:    remoteAddr(request),
+				UserAgent: request.UserAgent(),
+				Referer:   request.Referer(),
+				Subject:   info.subject,
+				RequestId: request.Header.Get(runtime.RequestIdHeader),
+			}
+			if spanCtx := trace.SpanContextFromContext(request.Context()); spanCtx.HasTraceID() {
+				record.TraceId = spanCtx.TraceID().String()
+			}
+			a.write(record)
+		})
+	})
+}
+
+func (a *accessLogger) sampled() bool {
+	ratio := a.cfg.SuccessSampleRatio
+	return ratio <= 0 || ratio >= 1 || rand.Float64() < ratio
+}
+
+func (a *accessLogger) write(record *AccessLogRecord) {
+	var line []byte
+	switch a.cfg.Format {
+	case AccessLogCombined:
+		line = []byte(formatCombined(record))
+	default:
+		b, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		line = append(b, '\n')
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cfg.Writer.Write(line)
+}
+
+// formatCombined returns
+// %h - %u [%t] "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func formatCombined(r *AccessLogRecord) string {
+	bytesOut := "-"
+	if r.BytesOut > 0 {
+		bytesOut = fmt.Sprint(r.BytesOut)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"\n",
+		r.Remote,
+		dashIfEmpty(r.Subject),
+		r.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, r.Path, r.Proto,
+		r.Status,
+		bytesOut,
+		dashIfEmpty(r.Referer),
+		dashIfEmpty(r.UserAgent),
+	)
+}
+
+func dashIfEmpty(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.ReplaceAll(s, "\"", "\\\"")
+}
+
+// remoteAddr returns the client address, which is the first address of
+// X-Forwarded-For when the gateway is behind proxies
+func remoteAddr(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); len(forwarded) > 0 {
+		client, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(client)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+// newTestHandler returns the gateway handler chain without a grpc backend
+func newTestHandler(t *testing.T, configurers ...HttpGatewayServerConfigurer) http.Handler {
+	sc := newConfig(logger.NewLogger(false), configurers...)
+	serveMux := pkgruntime.NewServeMux(sc.serveMuxOpts...)
+	err := serveMux.HandlePath(http.MethodPost, "/v1/projects/{projectId}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"` + pathParams["projectId"] + `"}`))
+	})
+	assert.NoError(t, err)
+	return chainMiddleware(serveMux, sc.middlewares...)
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := newTestHandler(t, WithAccessLog(AccessLogConfig{Writer: buf}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/projects/p1", strings.NewReader(`{"name":"x"}`))
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 127.0.0.1")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-Id", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	record := &AccessLogRecord{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), record))
+	assert.Equal(t, http.MethodPost, record.Method)
+	assert.Equal(t, "/v1/projects/p1", record.Path)
+	assert.Equal(t, "/v1/projects/{projectId}", record.Pattern)
+	assert.Equal(t, http.StatusCreated, record.Status)
+	assert.EqualValues(t, 12, record.BytesIn)
+	assert.EqualValues(t, 11, record.BytesOut)
+	assert.Equal(t, "10.0.0.1", record.Remote)
+	assert.Equal(t, "test-agent", record.UserAgent)
+	assert.Equal(t, "req-1", record.RequestId)
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := newTestHandler(t, WithAccessLog(AccessLogConfig{Format: AccessLogCombined, Writer: buf}))
+
+	req := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	req.RemoteAddr = "192.168.0.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "192.168.0.1 - - ["), line)
+	assert.Contains(t, line, `"GET /unknown HTTP/1.1" 404 `)
+}
+
+func TestAccessLogSubjectOfFailedCall(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sc := newConfig(logger.NewLogger(false), WithAccessLog(AccessLogConfig{Writer: buf}))
+	serveMux := pkgruntime.NewServeMux(sc.serveMuxOpts...)
+	assert.NoError(t, serveMux.HandlePath(http.MethodDelete, "/v1/projects/{projectId}", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		// like a generated handler of a call denied after authn
+		ctx := pkgruntime.NewServerMetadataContext(r.Context(), pkgruntime.ServerMetadata{HeaderMD: runtime.SubjectMetadata("user-1")})
+		_, outbound := pkgruntime.MarshalerForRequest(serveMux, r)
+		pkgruntime.HTTPError(ctx, serveMux, outbound, w, r, status.Error(codes.PermissionDenied, "denied"))
+	}))
+	chainMiddleware(serveMux, sc.middlewares...).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/v1/projects/p1", nil))
+
+	record := &AccessLogRecord{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), record))
+	assert.Equal(t, http.StatusForbidden, record.Status)
+	assert.Equal(t, "user-1", record.Subject)
+}
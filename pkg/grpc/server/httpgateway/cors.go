@@ -0,0 +1,209 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy sets the cross origin requests allowed from browsers, see
+// https://fetch.spec.whatwg.org/#http-cors-protocol
+type CORSPolicy struct {
+	// AllowedOrigins are exact origins, e.g. https://app.example.com. "*"
+	// allows all origins, it cannot be combined with AllowCredentials.
+	AllowedOrigins []string
+	// AllowedOriginPatterns must match whole origins, they are anchored, e.g.
+	// regexp.MustCompile(`https://[a-z0-9-]+\.example\.com`)
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowedMethods defaults to DefaultCORSMethods
+	AllowedMethods []string
+	// AllowedHeaders are request headers allowed by preflight requests,
+	// defaults to DefaultCORSHeaders
+	AllowedHeaders []string
+	// ExposedHeaders are response headers readable by scripts, X-Request-Id
+	// is always exposed
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and Authorization, the origin is echoed
+	// instead of "*". origins must be listed, as any site could make
+	// authenticated requests otherwise.
+	AllowCredentials bool
+	// MaxAge is how long preflight responses are cached, 0 leaves it to the
+	// browser
+	MaxAge time.Duration
+}
+
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	DefaultCORSHeaders = []string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "ResponseType", "X-Request-Id", "Idempotency-Key"}
+)
+
+type corsPolicy struct {
+	policy CORSPolicy
+}
+
+func (c corsPolicy) apply(sc *HTTPGatewayServerConfig) {
+	cors, err := newCORS(c.policy)
+	sc.cors = cors
+	// a later policy does not hide an invalid one
+	if sc.configErr == nil {
+		sc.configErr = err
+	}
+}
+
+// ErrInsecureCORSPolicy is returned by NewHTTPGatewayServer for policies
+// allowing credentials from all origins
+var ErrInsecureCORSPolicy = errors.New("cors: AllowCredentials cannot be combined with the \"*\" origin")
+
+// WithCORSPolicy answers preflight requests and sets cors headers of requests
+// from allowed origins. without a policy cross origin requests are not allowed.
+// invalid policies fail NewHTTPGatewayServer.
+func WithCORSPolicy(policy CORSPolicy) corsPolicy {
+	return corsPolicy{policy: policy}
+}
+
+// cors is a CORSPolicy prepared for matching
+type cors struct {
+	anyOrigin        bool
+	origins          map[string]struct{}
+	originPatterns   []*regexp.Regexp
+	methods          map[string]struct{}
+	headers          map[string]struct{}
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+func newCORS(policy CORSPolicy) (*cors, error) {
+	if len(policy.AllowedMethods) == 0 {
+		policy.AllowedMethods = DefaultCORSMethods
+	}
+	if len(policy.AllowedHeaders) == 0 {
+		policy.AllowedHeaders = DefaultCORSHeaders
+	}
+	c := &cors{
+		origins:          make(map[string]struct{}),
+		originPatterns:   make([]*regexp.Regexp, 0, len(policy.AllowedOriginPatterns)),
+		methods:          make(map[string]struct{}),
+		headers:          make(map[string]struct{}),
+		allowCredentials: policy.AllowCredentials,
+	}
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			if policy.AllowCredentials {
+				return nil, ErrInsecureCORSPolicy
+			}
+			c.anyOrigin = true
+		}
+		c.origins[strings.ToLower(origin)] = struct{}{}
+	}
+	for _, pattern := range policy.AllowedOriginPatterns {
+		// anchored, so https://app\.example\.com does not match
+		// https://app.example.com.evil.io
+		c.originPatterns = append(c.originPatterns, regexp.MustCompile(`^(?:`+pattern.String()+`)$`))
+	}
+	for _, method := range policy.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = struct{}{}
+	}
+	canonicalHeaders := make([]string, 0, len(policy.AllowedHeaders))
+	for _, header := range policy.AllowedHeaders {
+		canonical := http.CanonicalHeaderKey(header)
+		c.headers[canonical] = struct{}{}
+		canonicalHeaders = append(canonicalHeaders, canonical)
+	}
+	c.allowMethods = strings.Join(policy.AllowedMethods, ", ")
+	c.allowHeaders = strings.Join(canonicalHeaders, ", ")
+	c.exposeHeaders = strings.Join(append([]string{"X-Request-Id"}, policy.ExposedHeaders...), ", ")
+	if policy.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(policy.MaxAge.Seconds()))
+	}
+	return c, nil
+}
+
+func (c *cors) allowedOrigin(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	if _, found := c.origins[strings.ToLower(origin)]; found {
+		return true
+	}
+	for _, pattern := range c.originPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedHeaders returns true if all headers of Access-Control-Request-Headers
+// are allowed
+func (c *cors) allowedHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if len(header) == 0 {
+			continue
+		}
+		if _, found := c.headers[http.CanonicalHeaderKey(header)]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *cors) setAllowOrigin(h http.Header, origin string) {
+	if c.anyOrigin && !c.allowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && len(r.Header.Get("Origin")) > 0 && len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+func withCORS(c *cors) HttpMiddlewareHandler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if isPreflight(r) {
+				header := w.Header()
+				header.Add("Vary", "Origin")
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				_, methodAllowed := c.methods[r.Header.Get("Access-Control-Request-Method")]
+				if !c.allowedOrigin(origin) || !methodAllowed || !c.allowedHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				c.setAllowOrigin(header, origin)
+				header.Set("Access-Control-Allow-Methods", c.allowMethods)
+				header.Set("Access-Control-Allow-Headers", c.allowHeaders)
+				if len(c.maxAge) > 0 {
+					header.Set("Access-Control-Max-Age", c.maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if !c.anyOrigin || c.allowCredentials {
+				// the response depends on the origin, caches must not share it
+				w.Header().Add("Vary", "Origin")
+			}
+			if c.allowedOrigin(origin) {
+				c.setAllowOrigin(w.Header(), origin)
+				w.Header().Set("Access-Control-Expose-Headers", c.exposeHeaders)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
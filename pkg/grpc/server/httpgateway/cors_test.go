@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	handler := newTestHandler(t, WithCORSPolicy(CORSPolicy{
+		AllowedOrigins:        []string{"https://app.example.com"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`https://[a-z0-9-]+\.preview\.example\.com`)},
+		AllowCredentials:      true,
+		MaxAge:                10 * time.Minute,
+	}))
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/projects/p1", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", headers)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://app.example.com", http.MethodPut, "content-type, authorization")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPut)
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	w = preflight("https://pr-1.preview.example.com", http.MethodPost, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	for _, denied := range []*httptest.ResponseRecorder{
+		preflight("https://evil.example.com", http.MethodPost, ""),
+		preflight("https://pr-1.preview.example.com.evil.io", http.MethodPost, ""),
+		preflight("https://app.example.com", "TRACE", ""),
+		preflight("https://app.example.com", http.MethodPost, "X-Unknown"),
+	} {
+		assert.Equal(t, http.StatusForbidden, denied.Code)
+		assert.Empty(t, denied.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSRequest(t *testing.T) {
+	handler := newTestHandler(t, WithCORSPolicy(CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"Location"},
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/projects/p1", nil)
+	req.Header.Set("Origin", "https://any.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Request-Id, Location", w.Header().Get("Access-Control-Expose-Headers"))
+
+	// without a policy no cors headers are set
+	handler = newTestHandler(t)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSRejectsCredentialsFromAnyOrigin(t *testing.T) {
+	sc := newConfig(logger.NewLogger(false), WithCORSPolicy(CORSPolicy{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	}))
+	assert.ErrorIs(t, sc.configErr, ErrInsecureCORSPolicy)
+
+	sc = newConfig(logger.NewLogger(false), WithCORSPolicy(CORSPolicy{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	}), WithCORSPolicy(CORSPolicy{AllowedOrigins: []string{"https://app.example.com"}}))
+	assert.ErrorIs(t, sc.configErr, ErrInsecureCORSPolicy)
+}
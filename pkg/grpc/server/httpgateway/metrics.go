@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/felixge/httpsnoop"
+
+	"github.com/sdinsure/agent/pkg/metrics"
+)
+
+var httpServerNamespace = metrics.NewTypeNamespace("http_server")
+var httpSubsystem = metrics.NewTypeSubsystem("requests")
+
+// unmatchedRoute is the route label of requests not matching any route, e.g.
+// routing 404s which never reach grpc
+const unmatchedRoute = "unmatched"
+
+var (
+	metricHttpReqDur = metrics.NewTimeDurationHistogramVec(
+		httpServerNamespace,
+		httpSubsystem,
+		metrics.NewTypeMetricName("duration_ms"),
+		"method", "route",
+	)
+	metricHttpReqCodeTotal = metrics.NewCounterVec(
+		httpServerNamespace,
+		httpSubsystem,
+		metrics.NewTypeMetricName("code_total"),
+		"method", "route", "code",
+	)
+	metricHttpReqSize = metrics.NewByteSizeHistogramVec(
+		httpServerNamespace,
+		httpSubsystem,
+		metrics.NewTypeMetricName("request_size_bytes"),
+		"method", "route",
+	)
+	metricHttpRespSize = metrics.NewByteSizeHistogramVec(
+		httpServerNamespace,
+		httpSubsystem,
+		metrics.NewTypeMetricName("response_size_bytes"),
+		"method", "route",
+	)
+)
+
+// withMetrics records requests per route pattern, it should run after withRequestInfo
+func withMetrics(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body := &countingReader{ReadCloser: request.Body}
+		if request.Body != nil {
+			request.Body = body
+		}
+		m := httpsnoop.CaptureMetrics(handler, writer, request)
+
+		ctx := request.Context()
+		route := requestInfoFromContext(ctx).pattern
+		if len(route) == 0 {
+			route = unmatchedRoute
+		}
+		method := metricMethod(request.Method)
+		metricHttpReqDur.Observe(ctx, m.Duration, method, route)
+		metricHttpReqCodeTotal.Inc(ctx, method, route, strconv.Itoa(m.Code))
+		metricHttpReqSize.Observe(ctx, body.n, method, route)
+		metricHttpRespSize.Observe(ctx, m.Written, method, route)
+	})
+}
+
+// metricMethod keeps arbitrary methods sent by clients out of labels
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
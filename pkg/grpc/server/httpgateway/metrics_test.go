@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatherCodes returns http_server_requests_code_total by "method route code"
+func gatherCodes(t *testing.T) map[string]float64 {
+	families, err := prom.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	codes := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "http_server_requests_code_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			codes[labels["method"]+" "+labels["route"]+" "+labels["code"]] = m.GetCounter().GetValue()
+		}
+	}
+	return codes
+}
+
+func TestMetrics(t *testing.T) {
+	handler := newTestHandler(t)
+	before := gatherCodes(t)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/projects/p1", strings.NewReader(`{}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/not/found", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/v1/projects/p1", nil))
+
+	after := gatherCodes(t)
+	for key, value := range before {
+		after[key] -= value
+	}
+	assert.EqualValues(t, 1, after["POST /v1/projects/{projectId} 201"])
+	assert.EqualValues(t, 1, after["GET unmatched 404"])
+	assert.EqualValues(t, 1, after["OTHER unmatched 501"])
+}
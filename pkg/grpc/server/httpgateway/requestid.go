@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+// withRequestId accepts X-Request-Id from the client or generates one. the id
+// is forwarded to grpc via ForwardHttpToMetadata, and echoed in the response.
+func withRequestId(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		reqId := request.Header.Get(runtime.RequestIdHeader)
+		if !runtime.IsValidRequestId(reqId) {
+			reqId = runtime.NewRequestId()
+		}
+		request.Header.Set(runtime.RequestIdHeader, reqId)
+		writer.Header().Set(runtime.RequestIdHeader, reqId)
+
+		ctx := runtime.WithGivenRequestId(request.Context(), reqId)
+		ctx = grpc_ctxtags.SetInContext(ctx, grpc_ctxtags.NewTags().Set("request_id", reqId))
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", reqId))
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
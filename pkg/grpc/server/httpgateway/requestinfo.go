@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+// requestInfo is filled while the request goes through the serve mux, so outer
+// middlewares (e.g. access log) can read what only the mux knows about
+type requestInfo struct {
+	// pattern is the route pattern, e.g. /v1/projects/{projectId}, empty when
+	// no route matched
+	pattern string
+	// subject is sent back by the authn middleware
+	subject string
+	// streaming is set for server streams by recordStream, before the response
+	// is written
+	streaming bool
+}
+
+type requestInfoKey struct{}
+
+// withRequestInfo must run before middlewares reading requestInfoFromContext
+func withRequestInfo(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := context.WithValue(request.Context(), requestInfoKey{}, &requestInfo{})
+		handler.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, found := ctx.Value(requestInfoKey{}).(*requestInfo)
+	if !found {
+		return &requestInfo{}
+	}
+	return info
+}
+
+// singleSegmentCapture matches {name=*}, which is rendered as {name} in the
+// http rule, and by runtime.HTTPPathPattern
+var singleSegmentCapture = regexp.MustCompile(`\{([^=}]+)=\*\}`)
+
+// recordPattern is a serve mux middleware, which only runs on matched routes
+func recordPattern(next pkgruntime.HandlerFunc) pkgruntime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		if pattern, found := pkgruntime.HTTPPattern(r.Context()); found {
+			requestInfoFromContext(r.Context()).pattern = singleSegmentCapture.ReplaceAllString(pattern.String(), "{$1}")
+		}
+		next(w, r, pathParams)
+	}
+}
+
+// recordStream is a forward response option, the gateway calls it with a nil
+// message before forwarding messages of server streams. it must be the first
+// option.
+func recordStream(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	if resp == nil {
+		requestInfoFromContext(ctx).streaming = true
+	}
+	return nil
+}
+
+// recordSubject is a forward response option, reading grpc headers of the call
+func recordSubject(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	md, found := pkgruntime.ServerMetadataFromContext(ctx)
+	if !found {
+		return nil
+	}
+	if sub, found := runtime.SubjectFromHeader(md.HeaderMD); found {
+		requestInfoFromContext(ctx).subject = sub
+	}
+	return nil
+}
+
+// handleError records the subject of failed calls, e.g. denied by authz, as
+// forward response options only run on success
+func handleError(ctx context.Context, mux *pkgruntime.ServeMux, marshaler pkgruntime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	recordSubject(ctx, w, nil)
+	pkgruntime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+// DefaultResponseHeaders are the grpc headers forwarded as http response
+// headers, see runtime.SetHttpHeader
+var DefaultResponseHeaders = []string{
+	"Location",
+	"Cache-Control",
+	"ETag",
+	"Last-Modified",
+	"Expires",
+	"Content-Disposition",
+	"Content-Language",
+	"Retry-After",
+}
+
+type responseHeaders struct {
+	headers []string
+}
+
+func (r responseHeaders) apply(c *HTTPGatewayServerConfig) {
+	for _, header := range r.headers {
+		c.responseHeaders[strings.ToLower(header)] = struct{}{}
+	}
+}
+
+// WithResponseHeaders allows more grpc headers to be forwarded as http response
+// headers, in addition to DefaultResponseHeaders
+func WithResponseHeaders(headers ...string) responseHeaders {
+	return responseHeaders{headers: headers}
+}
+
+func newResponseHeaders() map[string]struct{} {
+	allowed := make(map[string]struct{}, len(DefaultResponseHeaders))
+	for _, header := range DefaultResponseHeaders {
+		allowed[strings.ToLower(header)] = struct{}{}
+	}
+	return allowed
+}
+
+// matchOutgoingHeader forwards allowed grpc headers as http headers, other
+// headers are dropped. x-request-id is not listed, as it is set by
+// withRequestId.
+func (sc *HTTPGatewayServerConfig) matchOutgoingHeader(key string) (string, bool) {
+	if _, allowed := sc.responseHeaders[strings.ToLower(key)]; allowed {
+		return http.CanonicalHeaderKey(key), true
+	}
+	return key, false
+}
+
+// forwardResponseStatus writes the status set by runtime.SetHttpStatus or
+// runtime.Redirect, responses with a Location header default to 302 Found.
+// streams always respond 200.
+func forwardResponseStatus(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	if resp == nil || requestInfoFromContext(ctx).streaming {
+		return nil
+	}
+	md, found := pkgruntime.ServerMetadataFromContext(ctx)
+	if !found {
+		return nil
+	}
+	status, found := runtime.HttpStatusFromHeader(md.HeaderMD)
+	if !found && len(w.Header().Get("Location")) > 0 {
+		status, found = http.StatusFound, true
+	}
+	if found {
+		w.WriteHeader(status)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+// headerStream records the headers set by grpc.SetHeader
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// serveUnary serves GET /v1/things like a generated unary handler, the grpc
+// headers are set by handler
+func serveUnary(t *testing.T, handler func(ctx context.Context), configurers ...HttpGatewayServerConfigurer) *httptest.ResponseRecorder {
+	return serveUnaryRequest(t, httptest.NewRequest(http.MethodGet, "/v1/things", nil), handler, configurers...)
+}
+
+func serveUnaryRequest(t *testing.T, req *http.Request, handler func(ctx context.Context), configurers ...HttpGatewayServerConfigurer) *httptest.ResponseRecorder {
+	sc := newConfig(logger.NewLogger(false), configurers...)
+	serveMux := pkgruntime.NewServeMux(sc.serveMuxOpts...)
+	assert.NoError(t, serveMux.HandlePath(http.MethodGet, "/v1/things", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		stream := &headerStream{}
+		handler(grpc.NewContextWithServerTransportStream(r.Context(), stream))
+
+		_, outbound := pkgruntime.MarshalerForRequest(serveMux, r)
+		ctx := pkgruntime.NewServerMetadataContext(r.Context(), pkgruntime.ServerMetadata{HeaderMD: stream.header})
+		pkgruntime.ForwardResponseMessage(ctx, serveMux, outbound, w, r, wrapperspb.String("thing"), serveMux.GetForwardResponseOptions()...)
+	}))
+	w := httptest.NewRecorder()
+	chainMiddleware(serveMux, sc.middlewares...).ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseStatusAndHeaders(t *testing.T) {
+	w := serveUnary(t, func(ctx context.Context) {
+		assert.NoError(t, runtime.SetHttpStatus(ctx, http.StatusCreated))
+		assert.NoError(t, runtime.SetHttpHeader(ctx, "Location", "/v1/things/1"))
+		assert.NoError(t, runtime.SetHttpHeader(ctx, "ETag", `"v1"`))
+		assert.NoError(t, runtime.SetHttpHeader(ctx, "X-Internal", "secret"))
+		assert.Error(t, runtime.SetHttpStatus(ctx, 99))
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/v1/things/1", w.Header().Get("Location"))
+	assert.Equal(t, `"v1"`, w.Header().Get("Etag"))
+	assert.Empty(t, w.Header().Get("X-Internal"))
+	assert.Empty(t, w.Header().Get("X-Http-Status"))
+	assert.Equal(t, `"thing"`, w.Body.String())
+
+	// headers outside of the defaults must be allowed
+	w = serveUnary(t, func(ctx context.Context) {
+		assert.NoError(t, runtime.SetHttpHeader(ctx, "X-Internal", "public"))
+	}, WithResponseHeaders("X-Internal"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public", w.Header().Get("X-Internal"))
+
+	// headers of forward response options are kept with a status
+	w = serveUnary(t, func(ctx context.Context) {
+		assert.NoError(t, runtime.SetHttpStatus(ctx, http.StatusAccepted))
+	}, WithServeMuxOption(pkgruntime.WithForwardResponseOption(func(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+		w.Header().Set("X-Modified", "true")
+		return nil
+	})))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "true", w.Result().Header.Get("X-Modified"))
+}
+
+func TestResponseRedirect(t *testing.T) {
+	w := serveUnary(t, func(ctx context.Context) {
+		assert.NoError(t, runtime.Redirect(ctx, "https://example.com/next", http.StatusTemporaryRedirect))
+		assert.Error(t, runtime.Redirect(ctx, "https://example.com/next", http.StatusOK))
+	})
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://example.com/next", w.Header().Get("Location"))
+
+	// a Location without status redirects with 302
+	w = serveUnary(t, func(ctx context.Context) {
+		assert.NoError(t, runtime.SetHttpHeader(ctx, "Location", "/login"))
+	})
+	assert.Equal(t, http.StatusFound, w.Code)
+}
+
+func TestResponseStatusWithTrailers(t *testing.T) {
+	// the gateway marks unary responses chunked for clients accepting trailers
+	req := httptest.NewRequest(http.MethodGet, "/v1/things", nil)
+	req.Header.Set("TE", "trailers")
+	w := serveUnaryRequest(t, req, func(ctx context.Context) {
+		assert.NoError(t, runtime.SetHttpStatus(ctx, http.StatusCreated))
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"thing"`, w.Body.String())
+}
@@ -3,6 +3,11 @@ package server
 import (
 	"net/http"
 
+	"github.com/sdinsure/agent/pkg/grpc/server/admin"
+	authnmiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/authn"
+	"github.com/sdinsure/agent/pkg/grpc/server/oidc"
+	"github.com/sdinsure/agent/pkg/grpc/server/session"
+	"github.com/sdinsure/agent/pkg/logger"
 	"github.com/sdinsure/agent/pkg/swagger"
 )
 
@@ -24,3 +29,48 @@ func NewOpenAPIV2Route(prefix string, handler http.Handler) *Route {
 		Handler: handler,
 	}
 }
+
+// NewLogLevelRoute serves the log levels of levels at pattern, e.g. /admin/loglevel.
+// the route is not covered by the grpc auth middlewares, wrap handler or keep
+// it off public listeners.
+func NewLogLevelRoute(pattern string, levels *logger.LevelController) *Route {
+	return &Route{
+		Pattern: pattern,
+		Handler: admin.NewLogLevelAdmin(levels),
+	}
+}
+
+// NewSessionRoutes serves POST <prefix>login and POST <prefix>logout, e.g. with
+// prefix /auth/. tokens are verified by parser before a session starts.
+func NewSessionRoutes(prefix string, sessions *session.Manager, parser authnmiddleware.ClaimParser) []*Route {
+	return []*Route{
+		{
+			Pattern: prefix + "login",
+			Handler: sessions.LoginHandler(parser),
+		},
+		{
+			Pattern: prefix + "logout",
+			Handler: sessions.LogoutHandler(),
+		},
+	}
+}
+
+// NewOIDCRoutes serves <prefix>login, <prefix>callback and POST <prefix>logout
+// of the oidc login flow, e.g. with prefix /auth/. the callback route must match
+// the redirect url of provider.
+func NewOIDCRoutes(prefix string, provider *oidc.Provider) []*Route {
+	return []*Route{
+		{
+			Pattern: prefix + "login",
+			Handler: provider.LoginHandler(),
+		},
+		{
+			Pattern: prefix + "callback",
+			Handler: provider.CallbackHandler(),
+		},
+		{
+			Pattern: prefix + "logout",
+			Handler: provider.LogoutHandler(),
+		},
+	}
+}
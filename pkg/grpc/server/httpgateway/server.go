@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -9,24 +10,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"regexp"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/felixge/httpsnoop"
 	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
-	"github.com/spf13/viper"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/protobuf/proto"
 
 	grpcserver "github.com/sdinsure/agent/pkg/grpc/server"
 	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+	"github.com/sdinsure/agent/pkg/grpc/server/session"
 	"github.com/sdinsure/agent/pkg/logger"
+	"github.com/sdinsure/agent/pkg/redact"
 )
 
 type HttpMiddlewareHandler func(h http.Handler) http.Handler
@@ -36,11 +35,23 @@ type HttpGatewayServerConfigurer interface {
 }
 
 type HTTPGatewayServerConfig struct {
+	// middlewares are added by WithHttpMiddlewares, newConfig prepends the
+	// builtins, so they hold the whole chain once configured
 	middlewares  []HttpMiddlewareHandler
 	serveMuxOpts []pkgruntime.ServeMuxOption
 
 	clientTransportCredentials credentials.TransportCredentials
 	log                        logger.Logger
+	requestId                  HttpMiddlewareHandler
+	redactor                   *redact.Redactor
+	accessLog                  *AccessLogConfig
+	sse                        *SSEConfig
+	webSocket                  *WebSocketConfig
+	cors                       *cors
+	session                    *session.Manager
+	responseHeaders            map[string]struct{}
+	// configErr is an invalid option, returned by NewHTTPGatewayServer
+	configErr error
 
 	maxCallRecvMsgSize int
 }
@@ -49,26 +60,60 @@ func newConfig(log logger.Logger, configer ...HttpGatewayServerConfigurer) *HTTP
 
 	// default server config
 	sc := &HTTPGatewayServerConfig{
-		log: log,
-		middlewares: []HttpMiddlewareHandler{
-			withLoggerWrapper(log),
-			cors,
-		},
+		log:       log,
+		requestId: withRequestId,
+		redactor:  redact.NewRedactor(),
 		serveMuxOpts: []pkgruntime.ServeMuxOption{
 			pkgruntime.WithRoutingErrorHandler(handleRoutingError),
-			pkgruntime.WithForwardResponseOption(responseHeaderMatcher),
+			pkgruntime.WithErrorHandler(handleError),
+			pkgruntime.WithForwardResponseOption(recordStream),
+			pkgruntime.WithForwardResponseOption(recordSubject),
+			pkgruntime.WithMiddlewares(recordPattern),
 			pkgruntime.WithIncomingHeaderMatcher(buildinHttpIncomingHeaderMatcher),
-			pkgruntime.WithOutgoingHeaderMatcher(buildinHttpOutgoingHeaderMatcher),
 			pkgruntime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
 				return runtime.ForwardHttpToMetadata(ctx, r)
 			}),
 		},
 		clientTransportCredentials: insecure.NewCredentials(),
 		maxCallRecvMsgSize:         10 * 1024 * 1024, /*10M for max receive size*/
+		responseHeaders:            newResponseHeaders(),
 	}
+	// before configers, so WithServeMuxOption can override the matcher
+	sc.serveMuxOpts = append(sc.serveMuxOpts, pkgruntime.WithOutgoingHeaderMatcher(sc.matchOutgoingHeader))
 	for _, config := range configer {
 		config.apply(sc)
 	}
+	// after configers, as headers set by forward response options of
+	// WithServeMuxOption are dropped once the status is written
+	sc.serveMuxOpts = append(sc.serveMuxOpts, pkgruntime.WithForwardResponseOption(forwardResponseStatus))
+	// built after configers, so WithLogger, WithRedactor, WithAccessLog,
+	// WithCORSPolicy, WithSession, WithWebSocketBridge and WithServerSentEvents
+	// apply
+	builtins := []HttpMiddlewareHandler{
+		sc.requestId,
+		withRequestInfo,
+		withMetrics,
+	}
+	if sc.accessLog != nil {
+		builtins = append(builtins, withAccessLog(*sc.accessLog))
+	}
+	builtins = append(builtins,
+		withLoggerWrapper(sc.log, sc.redactor, sc.accessLog == nil),
+	)
+	if sc.cors != nil {
+		builtins = append(builtins, withCORS(sc.cors))
+	}
+	if sc.session != nil {
+		// before the websocket bridge, so upgrades are authenticated by sessions
+		builtins = append(builtins, sc.session.Middleware)
+	}
+	if sc.webSocket != nil {
+		builtins = append(builtins, withWebSocketBridge(*sc.webSocket, sc.cors, sc.maxCallRecvMsgSize))
+	}
+	if sc.sse != nil {
+		builtins = append(builtins, withServerSentEvents(*sc.sse))
+	}
+	sc.middlewares = append(builtins, sc.middlewares...)
 	return sc
 }
 
@@ -84,6 +129,33 @@ func WithLogger(log logger.Logger) withLog {
 	return withLog{log: log}
 }
 
+type withRedactor struct {
+	redactor *redact.Redactor
+}
+
+func (w withRedactor) apply(c *HTTPGatewayServerConfig) {
+	c.redactor = w.redactor
+}
+
+// WithRedactor sets how bodies and headers of failed requests are logged
+func WithRedactor(redactor *redact.Redactor) withRedactor {
+	return withRedactor{redactor: redactor}
+}
+
+type withSession struct {
+	session *session.Manager
+}
+
+func (w withSession) apply(c *HTTPGatewayServerConfig) {
+	c.session = w.session
+}
+
+// WithSession authenticates browsers by session cookies, which are protected
+// from CSRF. see NewSessionRoutes for the login and logout routes.
+func WithSession(session *session.Manager) withSession {
+	return withSession{session: session}
+}
+
 type httpMiddlewares struct {
 	middlewares []HttpMiddlewareHandler
 }
@@ -145,6 +217,9 @@ func WithMaxCallRecvMsgSize(msgSize int) maxCallRecvMsgSize {
 func NewHTTPGatewayServer(g *grpcserver.GrpcServer, log logger.Logger, port int, configurers ...HttpGatewayServerConfigurer) (*HTTPGatewayServer, error) {
 
 	sc := newConfig(log, configurers...)
+	if sc.configErr != nil {
+		return nil, sc.configErr
+	}
 
 	opts := []grpc.DialOption{
 		//grpc.WithBlock(),
@@ -194,48 +269,28 @@ func handleRoutingError(ctx context.Context, mux *pkgruntime.ServeMux, marshaler
 	pkgruntime.DefaultRoutingErrorHandler(ctx, mux, marshaler, w, r, httpStatus)
 }
 
-// responseHeaderMatcher capture Location header in grpc.context and convert it to http 301 Redirection
-func responseHeaderMatcher(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
-	headers := w.Header()
-	if location, ok := headers["Grpc-Metadata-Location"]; ok {
-		w.Header().Set("Location", location[0])
-		w.WriteHeader(http.StatusFound)
-	}
-
-	return nil
-}
-
 // buildinHttpIncomingHeaderMatcher converts incoming http readers to grpc metadata
 func buildinHttpIncomingHeaderMatcher(header string) (string, bool) {
 	return pkgruntime.DefaultHeaderMatcher(header)
 }
 
-// buildinHttpOutgoingHeaderMatcher allows headers from grpc to http header
-func buildinHttpOutgoingHeaderMatcher(header string) (string, bool) {
-	var (
-		allowedHeaders = map[string]struct{}{
-			"x-request-id": {},
-		}
-	)
-	if _, isAllowed := allowedHeaders[header]; isAllowed {
-		return strings.ToUpper(header), true
-	}
-	return header, false
-}
-
-// withLogger add logs for each http reqeusts
-func withLoggerWrapper(log logger.Logger) HttpMiddlewareHandler {
+// withLogger add logs for each http reqeusts, bodies and headers of failed
+// requests are logged after redaction. logRequests is false when requests are
+// recorded by the access log.
+func withLoggerWrapper(log logger.Logger, redactor *redact.Redactor, logRequests bool) HttpMiddlewareHandler {
 	return HttpMiddlewareHandler(func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-			logWriter := &logResponseWriter{actualWriter: writer}
+			logWriter := &logResponseWriter{actualWriter: writer, maxLogBytes: redactor.MaxBodyBytes()}
 			r1, r2 := cloneRequest(request)
 			m := httpsnoop.CaptureMetrics(handler, logWriter, r1)
 			// printing exracted data
-			log.Info("http[%d]-- %s -- %s\n", m.Code, m.Duration, request.URL.Path)
-			if m.Code != 200 {
-				log.Info("(conti) body: %s\n", string(logWriter.log.Bytes()))
+			if logRequests {
+				log.Infox(request.Context(), "http[%d]-- %s -- %s\n", m.Code, m.Duration, request.URL.Path)
+			}
+			if m.Code >= 400 {
+				log.Infox(request.Context(), "(conti) body: %s\n", redactor.Body(logWriter.log.Bytes()))
 				fullBody, _ := io.ReadAll(r2.Body)
-				log.Info("(conti) request body:%s\n", string(fullBody))
+				log.Infox(request.Context(), "(conti) request headers:%v, body:%s\n", redactor.Header(request.Header), redactor.Body(fullBody))
 			}
 		})
 	})
@@ -259,6 +314,8 @@ func cloneRequest(r *http.Request) (r1 *http.Request, r2 *http.Request) {
 type logResponseWriter struct {
 	log          bytes.Buffer
 	actualWriter http.ResponseWriter
+	// maxLogBytes caps the bytes kept in log, the rest is only written to actualWriter
+	maxLogBytes int
 }
 
 func (l *logResponseWriter) Header() http.Header {
@@ -266,14 +323,25 @@ func (l *logResponseWriter) Header() http.Header {
 }
 
 func (l *logResponseWriter) Write(b []byte) (int, error) {
-	mw := io.MultiWriter(l.actualWriter, &l.log)
-	return mw.Write(b)
+	// keep one more byte than the cap, so redaction knows the body was truncated
+	if remaining := l.maxLogBytes + 1 - l.log.Len(); remaining > 0 {
+		if remaining > len(b) {
+			remaining = len(b)
+		}
+		l.log.Write(b[:remaining])
+	}
+	return l.actualWriter.Write(b)
 }
 
 func (l *logResponseWriter) WriteHeader(code int) {
 	l.actualWriter.WriteHeader(code)
 }
 
+// Hijack supports websocket upgrades
+func (l *logResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(l.actualWriter).Hijack()
+}
+
 func (l *logResponseWriter) Flush() {
 	f, ok := l.actualWriter.(http.Flusher)
 	if ok {
@@ -282,31 +350,6 @@ func (l *logResponseWriter) Flush() {
 	l.log.Reset()
 }
 
-func cors(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if allowedOrigin(r.Header.Get("Origin")) {
-			w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, ResponseType")
-		}
-		if r.Method == "OPTIONS" {
-			return
-		}
-		h.ServeHTTP(w, r)
-	})
-}
-
-func allowedOrigin(origin string) bool {
-	//log.Printf("cors pattern:%s, origin from request:%s\n", viper.GetString("cors"), origin)
-	if viper.GetString("cors") == "*" {
-		return true
-	}
-	if matched, _ := regexp.MatchString(viper.GetString("cors"), origin); matched {
-		return true
-	}
-	return false
-}
-
 type HTTPGatewayServer struct {
 	port       int
 	log        logger.Logger
@@ -359,7 +402,7 @@ func (h *HTTPGatewayServer) ListenAndServe() error {
 
 func (h *HTTPGatewayServer) WaitForSIGTERM() error {
 	h.log.Info("httpgateway: wait for system interrupt...\n")
-	signalCh := make(chan os.Signal)
+	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	<-signalCh
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const eventStreamContentType = "text/event-stream"
+
+type SSEConfig struct {
+	// HeartbeatInterval writes a keepalive comment to idle streams, so proxies
+	// do not close them. defaults to 15s, negative disables heartbeats.
+	HeartbeatInterval time.Duration
+}
+
+type serverSentEvents struct {
+	cfg SSEConfig
+}
+
+func (s serverSentEvents) apply(c *HTTPGatewayServerConfig) {
+	c.sse = &s.cfg
+}
+
+// WithServerSentEvents serves server streaming methods as text/event-stream to
+// clients accepting it, e.g. EventSource. each message is an event with an
+// increasing id, and a failed stream ends with an "error" event carrying the
+// grpc status as data. other methods are not affected.
+func WithServerSentEvents(cfg SSEConfig) serverSentEvents {
+	return serverSentEvents{cfg: cfg}
+}
+
+func withServerSentEvents(cfg SSEConfig) HttpMiddlewareHandler {
+	if cfg.HeartbeatInterval == 0 {
+		cfg.HeartbeatInterval = 15 * time.Second
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acceptsEventStream(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			sw := &sseResponseWriter{w: w, info: requestInfoFromContext(r.Context()), heartbeatInterval: cfg.HeartbeatInterval}
+			defer sw.close()
+			h.ServeHTTP(sw, r)
+		})
+	}
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err == nil && mediaType == eventStreamContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sseResponseWriter rewrites the newline delimited {"result":...} and
+// {"error":...} chunks written by the gateway for server streams into events.
+// responses of unary methods are written as is.
+type sseResponseWriter struct {
+	w                 http.ResponseWriter
+	info              *requestInfo
+	heartbeatInterval time.Duration
+
+	mu          sync.Mutex
+	decided     bool
+	streaming   bool
+	wroteHeader bool
+	pending     bytes.Buffer
+	nextId      int
+	stop        chan struct{}
+	stopped     chan struct{}
+}
+
+func (s *sseResponseWriter) Header() http.Header {
+	return s.w.Header()
+}
+
+// decideLocked detects server streams, they are marked by recordStream before
+// anything is written
+func (s *sseResponseWriter) decideLocked() {
+	if s.decided {
+		return
+	}
+	s.decided = true
+	if !s.info.streaming {
+		return
+	}
+	header := s.w.Header()
+	s.streaming = true
+	header.Del("Transfer-Encoding")
+	header.Del("Content-Length")
+	header.Set("Content-Type", eventStreamContentType)
+	header.Set("Cache-Control", "no-cache")
+	// disables response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	if s.heartbeatInterval > 0 {
+		s.stop = make(chan struct{})
+		s.stopped = make(chan struct{})
+		go s.heartbeat()
+	}
+}
+
+func (s *sseResponseWriter) WriteHeader(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decideLocked()
+	if s.streaming {
+		// EventSource does not read bodies of failed responses, errors of
+		// streams are sent as events instead
+		code = http.StatusOK
+		s.w.Header().Set("Content-Type", eventStreamContentType)
+		if s.wroteHeader {
+			// a heartbeat was written already
+			return
+		}
+	}
+	s.wroteHeader = true
+	s.w.WriteHeader(code)
+}
+
+func (s *sseResponseWriter) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decideLocked()
+	s.wroteHeader = true
+	if !s.streaming {
+		return s.w.Write(b)
+	}
+	s.pending.Write(b)
+	for {
+		line, err := s.pending.ReadBytes('\n')
+		if err != nil {
+			// keep the partial line until its delimiter is written
+			s.pending.Reset()
+			s.pending.Write(line)
+			return len(b), nil
+		}
+		if err := s.writeEventLocked(bytes.TrimRight(line, "\r\n")); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (s *sseResponseWriter) writeEventLocked(line []byte) error {
+	if len(line) == 0 {
+		return nil
+	}
+	var chunk struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	event := &bytes.Buffer{}
+	switch err := json.Unmarshal(line, &chunk); {
+	case err == nil && len(chunk.Error) > 0:
+		fmt.Fprintf(event, "event: error\ndata: %s\n\n", chunk.Error)
+	case err == nil && len(chunk.Result) > 0:
+		s.nextId++
+		fmt.Fprintf(event, "id: %d\ndata: %s\n\n", s.nextId, chunk.Result)
+	default:
+		// e.g. chunks of google.api.HttpBody streams
+		s.nextId++
+		fmt.Fprintf(event, "id: %d\ndata: %s\n\n", s.nextId, line)
+	}
+	_, err := s.w.Write(event.Bytes())
+	return err
+}
+
+func (s *sseResponseWriter) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+}
+
+func (s *sseResponseWriter) flushLocked() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *sseResponseWriter) Unwrap() http.ResponseWriter {
+	return s.w
+}
+
+func (s *sseResponseWriter) heartbeat() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.wroteHeader = true
+			_, err := s.w.Write([]byte(": keepalive\n\n"))
+			if err == nil {
+				s.flushLocked()
+			}
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *sseResponseWriter) close() {
+	s.mu.Lock()
+	stop, stopped := s.stop, s.stopped
+	if s.streaming && s.pending.Len() > 0 {
+		s.writeEventLocked(s.pending.Bytes())
+		s.pending.Reset()
+	}
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+// newStreamTestHandler serves GET /v1/stream like a generated server streaming
+// handler, sending msgs then err
+func newStreamTestHandler(t *testing.T, msgs []string, err error, delay time.Duration, configurers ...HttpGatewayServerConfigurer) http.Handler {
+	sc := newConfig(logger.NewLogger(false), configurers...)
+	serveMux := pkgruntime.NewServeMux(sc.serveMuxOpts...)
+	assert.NoError(t, serveMux.HandlePath(http.MethodGet, "/v1/stream", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := pkgruntime.MarshalerForRequest(serveMux, r)
+		ctx := pkgruntime.NewServerMetadataContext(r.Context(), pkgruntime.ServerMetadata{})
+		i := 0
+		pkgruntime.ForwardResponseStream(ctx, serveMux, outbound, w, r, func() (proto.Message, error) {
+			time.Sleep(delay)
+			if i < len(msgs) {
+				i++
+				return wrapperspb.String(msgs[i-1]), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}, serveMux.GetForwardResponseOptions()...)
+	}))
+	return chainMiddleware(serveMux, sc.middlewares...)
+}
+
+func serveStream(handler http.Handler, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/stream", nil)
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerSentEvents(t *testing.T) {
+	handler := newStreamTestHandler(t, []string{"a", "b"}, status.Error(codes.NotFound, "gone"), 0,
+		WithServerSentEvents(SSEConfig{HeartbeatInterval: -1}))
+
+	w := serveStream(handler, "text/event-stream")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id: 1\ndata: \"a\"\n\n"+
+		"id: 2\ndata: \"b\"\n\n"+
+		"event: error\ndata: {\"code\":5,\"message\":\"gone\",\"details\":[]}\n\n", w.Body.String())
+
+	// clients not asking for events get newline delimited json
+	w = serveStream(handler, "")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), `{"result":"a"}`+"\n"))
+}
+
+func TestServerSentEventsFailedStream(t *testing.T) {
+	handler := newStreamTestHandler(t, nil, status.Error(codes.PermissionDenied, "denied"), 0,
+		WithServerSentEvents(SSEConfig{HeartbeatInterval: -1}))
+
+	w := serveStream(handler, "text/event-stream")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "event: error\ndata: {\"code\":7")
+}
+
+func TestServerSentEventsHeartbeat(t *testing.T) {
+	handler := newStreamTestHandler(t, []string{"a"}, nil, 100*time.Millisecond,
+		WithServerSentEvents(SSEConfig{HeartbeatInterval: 10 * time.Millisecond}))
+
+	w := serveStream(handler, "text/event-stream")
+	assert.Contains(t, w.Body.String(), ": keepalive\n\n")
+	assert.Contains(t, w.Body.String(), "id: 1\ndata: \"a\"\n\n")
+}
+
+func TestServerSentEventsUnary(t *testing.T) {
+	// unary responses are chunked for clients accepting trailers, and are not
+	// events
+	req := httptest.NewRequest(http.MethodGet, "/v1/things", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("TE", "trailers")
+	w := serveUnaryRequest(t, req, func(context.Context) {}, WithServerSentEvents(SSEConfig{HeartbeatInterval: -1}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"thing"`, w.Body.String())
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+
+	grpcmetadata "github.com/sdinsure/agent/pkg/grpc/server/metadata"
+)
+
+const (
+	// WebSocketProtocol is the subprotocol selected by the bridge, browsers
+	// fail the handshake if none of the offered subprotocols is selected
+	WebSocketProtocol = "grpc-gateway"
+	// webSocketBearerPrefix offers a token as subprotocol, EventSource and
+	// WebSocket of browsers cannot set Authorization, e.g.
+	//
+	//	new WebSocket(url, ["grpc-gateway", "bearer." + token])
+	webSocketBearerPrefix = "bearer."
+)
+
+type WebSocketConfig struct {
+	// CheckOrigin accepts the handshake of browsers, defaults to origins of
+	// the same host and origins allowed by WithCORSPolicy
+	CheckOrigin func(r *http.Request) bool
+}
+
+type webSocketBridge struct {
+	cfg WebSocketConfig
+}
+
+func (w webSocketBridge) apply(c *HTTPGatewayServerConfig) {
+	c.webSocket = &w.cfg
+}
+
+// WithWebSocketBridge serves websocket upgrades of gateway routes, e.g. of bidi
+// streaming methods. each text frame received is a json request message, each
+// message of the response stream is sent as a {"result":...} or {"error":...}
+// text frame. the response of unary methods is sent as a single frame, and a
+// call failing before the response stream starts as an {"error":...} frame.
+// an empty text frame closes the request stream (CloseSend), and the
+// connection is closed once the response ends.
+//
+// the method of the route defaults to POST, and is overridden by the "method"
+// query parameter. the token is read from the "bearer.<token>" subprotocol,
+// then from the Authorization cookie.
+func WithWebSocketBridge(cfg WebSocketConfig) webSocketBridge {
+	return webSocketBridge{cfg: cfg}
+}
+
+func withWebSocketBridge(cfg WebSocketConfig, c *cors, maxMessageBytes int) HttpMiddlewareHandler {
+	if cfg.CheckOrigin == nil {
+		cfg.CheckOrigin = sameHostOrAllowedOrigin(c)
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isWebSocketUpgrade(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			server := websocket.Server{
+				Handshake: func(config *websocket.Config, r *http.Request) error {
+					if !cfg.CheckOrigin(r) {
+						return websocket.ErrBadWebSocketOrigin
+					}
+					// never echo the token
+					selected := []string{}
+					for _, protocol := range config.Protocol {
+						if protocol == WebSocketProtocol {
+							selected = append(selected, protocol)
+						}
+					}
+					config.Protocol = selected
+					return nil
+				},
+				Handler: func(ws *websocket.Conn) {
+					ws.MaxPayloadBytes = maxMessageBytes
+					bridgeWebSocket(h, ws, r)
+				},
+			}
+			server.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+func sameHostOrAllowedOrigin(c *cors) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if len(origin) == 0 {
+			// not a browser
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+			return true
+		}
+		return c != nil && c.allowedOrigin(origin)
+	}
+}
+
+// bridgeWebSocket serves ws by h, with a request streaming received frames
+func bridgeWebSocket(h http.Handler, ws *websocket.Conn, upgrade *http.Request) {
+	ctx, cancel := context.WithCancel(upgrade.Context())
+	defer cancel()
+
+	body, bodyWriter := io.Pipe()
+	go func() {
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				// the client is gone, cancel the call
+				bodyWriter.CloseWithError(err)
+				cancel()
+				return
+			}
+			if len(msg) == 0 {
+				bodyWriter.Close()
+				continue
+			}
+			if _, err := io.WriteString(bodyWriter, msg+"\n"); err != nil {
+				// request stream is closed, ignore further messages
+				continue
+			}
+		}
+	}()
+
+	rw := &webSocketResponseWriter{ws: ws, header: http.Header{}, info: requestInfoFromContext(upgrade.Context())}
+	h.ServeHTTP(rw, newWebSocketRequest(ctx, upgrade, body))
+	rw.Flush()
+	ws.Close()
+}
+
+func newWebSocketRequest(ctx context.Context, upgrade *http.Request, body io.ReadCloser) *http.Request {
+	r := upgrade.Clone(ctx)
+	r.Method = http.MethodPost
+	if method := r.URL.Query().Get("method"); len(method) > 0 {
+		r.Method = strings.ToUpper(method)
+	}
+	r.Body = body
+	r.ContentLength = -1
+	for _, name := range []string{"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol", "Accept"} {
+		r.Header.Del(name)
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	if len(r.Header.Get("Authorization")) > 0 {
+		return r
+	}
+	for _, protocol := range upgrade.Header.Values("Sec-Websocket-Protocol") {
+		for _, p := range strings.Split(protocol, ",") {
+			if token, found := strings.CutPrefix(strings.TrimSpace(p), webSocketBearerPrefix); found {
+				r.Header.Set("Authorization", "Bearer "+token)
+				return r
+			}
+		}
+	}
+	if values := grpcmetadata.HttpCookiesToGrpcMetadata(ctx, upgrade).Get("authorization"); len(values) > 0 {
+		r.Header.Set("Authorization", values[0])
+	}
+	return r
+}
+
+// webSocketResponseWriter sends each line written by the gateway as a text frame
+type webSocketResponseWriter struct {
+	ws     *websocket.Conn
+	header http.Header
+	info   *requestInfo
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+	// failed is set for error responses outside of streams, whose body is
+	// sent as a single {"error":...} frame by Flush
+	failed bool
+}
+
+func (w *webSocketResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader marks failed calls, streams send their errors as {"error":...}
+// lines already
+func (w *webSocketResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if (code < 200 || code > 299) && !w.info.streaming {
+		w.failed = true
+	}
+}
+
+func (w *webSocketResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending.Write(b)
+	if w.failed {
+		return len(b), nil
+	}
+	for {
+		line, err := w.pending.ReadBytes('\n')
+		if err != nil {
+			// keep the partial line until its delimiter is written
+			w.pending.Reset()
+			w.pending.Write(line)
+			return len(b), nil
+		}
+		if err := w.sendLocked(bytes.TrimRight(line, "\r\n")); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush sends a pending message without delimiter, e.g. of unary methods
+func (w *webSocketResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	msg := bytes.TrimSpace(w.pending.Bytes())
+	w.pending.Reset()
+	if w.failed && len(msg) > 0 {
+		if !json.Valid(msg) {
+			// e.g. http.Error of middlewares
+			msg, _ = json.Marshal(string(msg))
+		}
+		msg = []byte(fmt.Sprintf(`{"error":%s}`, msg))
+	}
+	w.sendLocked(msg)
+}
+
+func (w *webSocketResponseWriter) sendLocked(msg []byte) error {
+	if len(msg) == 0 {
+		return nil
+	}
+	return websocket.Message.Send(w.ws, string(msg))
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	pkgruntime "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+// newEchoServer serves POST /v1/echo like a bidi streaming handler, echoing
+// each request message as result
+func newEchoServer(t *testing.T) (*httptest.Server, func() string) {
+	var mu sync.Mutex
+	var authorization string
+	sc := newConfig(logger.NewLogger(false), WithWebSocketBridge(WebSocketConfig{}))
+	serveMux := pkgruntime.NewServeMux(sc.serveMuxOpts...)
+	assert.NoError(t, serveMux.HandlePath(http.MethodPost, "/v1/echo", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		mu.Lock()
+		authorization = r.Header.Get("Authorization")
+		mu.Unlock()
+		decoder := json.NewDecoder(r.Body)
+		for {
+			var msg map[string]interface{}
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+			b, _ := json.Marshal(map[string]interface{}{"result": msg})
+			w.Write(append(b, '\n'))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	srv := httptest.NewServer(chainMiddleware(serveMux, sc.middlewares...))
+	t.Cleanup(srv.Close)
+	return srv, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return authorization
+	}
+}
+
+func dialEcho(t *testing.T, srv *httptest.Server, origin string, protocols ...string) (*websocket.Conn, error) {
+	return dialPath(t, srv, "/v1/echo", origin, protocols...)
+}
+
+func dialPath(t *testing.T, srv *httptest.Server, path string, origin string, protocols ...string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig(strings.Replace(srv.URL, "http://", "ws://", 1)+path, origin)
+	assert.NoError(t, err)
+	config.Protocol = protocols
+	config.Header = http.Header{}
+	if len(protocols) == 0 {
+		config.Header.Set("Cookie", "Authorization=Bearer from-cookie")
+	}
+	return websocket.DialConfig(config)
+}
+
+func TestWebSocketBridge(t *testing.T) {
+	srv, authorization := newEchoServer(t)
+
+	ws, err := dialEcho(t, srv, srv.URL, WebSocketProtocol, "bearer.token")
+	assert.NoError(t, err)
+	defer ws.Close()
+	assert.Equal(t, []string{WebSocketProtocol}, ws.Config().Protocol)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, websocket.Message.Send(ws, fmt.Sprintf(`{"name":"n%d"}`, i)))
+		var reply string
+		assert.NoError(t, websocket.Message.Receive(ws, &reply))
+		assert.Equal(t, fmt.Sprintf(`{"result":{"name":"n%d"}}`, i), reply)
+	}
+	// close send, the server ends the stream and the connection
+	assert.NoError(t, websocket.Message.Send(ws, ""))
+	var reply string
+	assert.ErrorIs(t, websocket.Message.Receive(ws, &reply), io.EOF)
+	assert.Equal(t, "Bearer token", authorization())
+}
+
+func TestWebSocketBridgeCookie(t *testing.T) {
+	srv, authorization := newEchoServer(t)
+
+	ws, err := dialEcho(t, srv, srv.URL)
+	assert.NoError(t, err)
+	assert.NoError(t, websocket.Message.Send(ws, `{}`))
+	var reply string
+	assert.NoError(t, websocket.Message.Receive(ws, &reply))
+	ws.Close()
+	assert.Equal(t, "Bearer from-cookie", authorization())
+
+	// origins of other hosts need a cors policy
+	_, err = dialEcho(t, srv, "http://evil.example.com", WebSocketProtocol)
+	assert.Error(t, err)
+}
+
+func TestWebSocketBridgeError(t *testing.T) {
+	srv, _ := newEchoServer(t)
+
+	// the call fails before a response stream
+	ws, err := dialPath(t, srv, "/v1/unknown", srv.URL, WebSocketProtocol)
+	assert.NoError(t, err)
+	defer ws.Close()
+	var reply string
+	assert.NoError(t, websocket.Message.Receive(ws, &reply))
+	assert.JSONEq(t, `{"error":{"code":5,"message":"Not Found","details":[]}}`, reply)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"google.golang.org/grpc"
 
 	sderrors "github.com/sdinsure/agent/pkg/errors"
 	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
@@ -66,7 +67,7 @@ func newOption(opts ...Optional) *Option {
 
 func NewAuthNMiddleware(l logger.Logger, claimParser ClaimParser, options ...Optional) *AuthNMiddleware {
 	return &AuthNMiddleware{
-		log:         l,
+		log:         logger.Sampled(l, logger.DefaultSampling),
 		tokenParser: &bearerTokenParser{l: l},
 		claimParser: claimParser,
 		option:      newOption(options...),
@@ -106,6 +107,10 @@ func (a *AuthNMiddleware) AuthFunc(ctx context.Context) (context.Context, error)
 	}
 
 	sub, _ := claims.GetSubject()
+	if len(sub) > 0 {
+		// read by the http gateway for access logs, fails only outside of a grpc call
+		grpc.SetHeader(ctx, runtime.SubjectMetadata(sub))
+	}
 	a.log.Info("auth: populate relavant info to ctx\n")
 	return runtime.WithKeyInfo(
 		runtime.WithClaimInfo(
@@ -69,7 +69,7 @@ type Enforcer interface {
 
 func NewAuthZMiddleware(log logger.Logger, enforcer Enforcer, optioners ...AuthZMiddlewareOptioner) *AuthzMiddleware {
 	opt := newOptions(optioners...)
-	return &AuthzMiddleware{log: log, enforcer: enforcer, opt: opt}
+	return &AuthzMiddleware{log: logger.Sampled(log, logger.DefaultSampling), enforcer: enforcer, opt: opt}
 }
 
 type AuthzMiddleware struct {
@@ -0,0 +1,226 @@
+package idempotencymiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/middleware"
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+var (
+	_ middleware.ServerMiddleware = &IdempotencyMiddleware{}
+)
+
+type IdempotencyMiddlewareOptioner interface {
+	apply(o *IdempotencyMiddlewareOptions)
+}
+
+type IdempotencyMiddlewareOptions struct {
+	ttl         time.Duration
+	lockTimeout time.Duration
+	methods     map[string]struct{}
+}
+
+type ttlOption struct {
+	ttl time.Duration
+}
+
+func (t ttlOption) apply(o *IdempotencyMiddlewareOptions) {
+	o.ttl = t.ttl
+}
+
+// WithTTL sets how long a stored response is replayed for the same key
+func WithTTL(ttl time.Duration) IdempotencyMiddlewareOptioner {
+	return ttlOption{ttl: ttl}
+}
+
+type lockTimeoutOption struct {
+	lockTimeout time.Duration
+}
+
+func (l lockTimeoutOption) apply(o *IdempotencyMiddlewareOptions) {
+	o.lockTimeout = l.lockTimeout
+}
+
+// WithLockTimeout sets how long a key stays reserved by a request in flight.
+// a request which never completes, e.g. the process crashed, blocks retries
+// with the same key until then. it should exceed the handler deadline.
+func WithLockTimeout(lockTimeout time.Duration) IdempotencyMiddlewareOptioner {
+	return lockTimeoutOption{lockTimeout: lockTimeout}
+}
+
+type methodsOption struct {
+	methods []string
+}
+
+func (m methodsOption) apply(o *IdempotencyMiddlewareOptions) {
+	if o.methods == nil {
+		o.methods = make(map[string]struct{})
+	}
+	for _, method := range m.methods {
+		o.methods[method] = struct{}{}
+	}
+}
+
+// WithMethods restricts idempotency handling to the given grpc full methods,
+// e.g. /example.api.HelloService/SayHello. by default, every unary method
+// called with an idempotency key via a non-safe http verb is handled.
+func WithMethods(fullMethods ...string) IdempotencyMiddlewareOptioner {
+	return methodsOption{methods: fullMethods}
+}
+
+func newOptions(opts ...IdempotencyMiddlewareOptioner) *IdempotencyMiddlewareOptions {
+	o := &IdempotencyMiddlewareOptions{
+		ttl:         24 * time.Hour,
+		lockTimeout: time.Minute,
+	}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	return o
+}
+
+// NewIdempotencyMiddleware replays the first response of a mutating rpc for
+// requests carrying the same Idempotency-Key. it should be placed after the
+// authn middleware, as the key is scoped by the caller's subject.
+func NewIdempotencyMiddleware(log logger.Logger, store Store, optioners ...IdempotencyMiddlewareOptioner) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		log:   log,
+		store: store,
+		opt:   newOptions(optioners...),
+	}
+}
+
+type IdempotencyMiddleware struct {
+	log   logger.Logger
+	store Store
+	opt   *IdempotencyMiddlewareOptions
+}
+
+func (i *IdempotencyMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		idempotencyKey, found := runtime.IdempotencyKey(ctx)
+		if !found || len(idempotencyKey) == 0 || !i.isApplicable(ctx, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		requestHash, err := hashRequest(req)
+		if err != nil {
+			return handler(ctx, req)
+		}
+
+		key := storeKey(ctx, info.FullMethod, idempotencyKey)
+		existing, reserved, err := i.store.Reserve(ctx, key, requestHash, time.Now().Add(i.opt.lockTimeout))
+		if err != nil {
+			i.log.Errorx(ctx, "idempotency: reserve key failed, err:%+v\n", err)
+			return nil, err
+		}
+		if !reserved {
+			return i.replay(ctx, existing, requestHash)
+		}
+
+		resp, handlerErr := handler(ctx, req)
+		if isRetryable(handlerErr) {
+			// allow clients to retry with the same key
+			if err := i.store.Release(ctx, key); err != nil {
+				i.log.Errorx(ctx, "idempotency: release key failed, err:%+v\n", err)
+			}
+			return resp, handlerErr
+		}
+		record, err := newCompletedRecord(requestHash, resp, handlerErr)
+		if err != nil {
+			i.log.Errorx(ctx, "idempotency: encode record failed, err:%+v\n", err)
+			if err := i.store.Release(ctx, key); err != nil {
+				i.log.Errorx(ctx, "idempotency: release key failed, err:%+v\n", err)
+			}
+			return resp, handlerErr
+		}
+		if err := i.store.Complete(ctx, key, record, time.Now().Add(i.opt.ttl)); err != nil {
+			i.log.Errorx(ctx, "idempotency: complete key failed, err:%+v\n", err)
+		}
+		return resp, handlerErr
+	}
+}
+
+func (i *IdempotencyMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		// streaming responses can not be replayed
+		return handler(srv, stream)
+	}
+}
+
+func (i *IdempotencyMiddleware) isApplicable(ctx context.Context, fullMethod string) bool {
+	if i.opt.methods != nil {
+		_, found := i.opt.methods[fullMethod]
+		return found
+	}
+	httpVerb, _ := runtime.HttpVerb(ctx)
+	switch strings.ToUpper(httpVerb) {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+func (i *IdempotencyMiddleware) replay(ctx context.Context, record *Record, requestHash string) (interface{}, error) {
+	if record.RequestHash != requestHash {
+		return nil, status.Error(codes.InvalidArgument, "idempotency: key was used with a different request")
+	}
+	if !record.Completed {
+		return nil, status.Error(codes.Aborted, "idempotency: a request with the same key is in progress")
+	}
+	i.log.Infox(ctx, "idempotency: replay stored response\n")
+	if len(record.Status) > 0 {
+		st, err := unmarshalStatus(record.Status)
+		if err != nil {
+			return nil, err
+		}
+		if st.Code() != codes.OK {
+			return nil, st.Err()
+		}
+	}
+	anyResp := &anypb.Any{}
+	if err := proto.Unmarshal(record.Response, anyResp); err != nil {
+		return nil, err
+	}
+	return anyResp.UnmarshalNew()
+}
+
+// storeKey scopes the client key by subject and method, so different callers
+// or different rpcs never collide
+func storeKey(ctx context.Context, fullMethod, idempotencyKey string) string {
+	sub, _ := runtime.SubInfo(ctx)
+	return fmt.Sprintf("%s|%s|%s", sub, fullMethod, idempotencyKey)
+}
+
+func hashRequest(req interface{}) (string, error) {
+	msg, isProto := req.(proto.Message)
+	if !isProto {
+		return "", fmt.Errorf("idempotency: request is not a proto message")
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package idempotencymiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sdinsure/agent/pkg/logger"
+)
+
+func TestReplayStoredResponse(t *testing.T) {
+	m := NewIdempotencyMiddleware(logger.NewLogger(true), NewMemoryStore())
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "key1", "http-verb", "POST"))
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return wrapperspb.String("created"), nil
+	}
+
+	resp, err := interceptor(ctx, wrapperspb.String("req"), info, handler)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "created", resp.(*wrapperspb.StringValue).GetValue())
+
+	resp, err = interceptor(ctx, wrapperspb.String("req"), info, handler)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(wrapperspb.String("created"), resp.(proto.Message)))
+	assert.EqualValues(t, 1, calls)
+
+	// same key with a different payload is rejected
+	_, err = interceptor(ctx, wrapperspb.String("another"), info, handler)
+	assert.EqualValues(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestInFlightDuplicateIsAborted(t *testing.T) {
+	m := NewIdempotencyMiddleware(logger.NewLogger(true), NewMemoryStore())
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "key1"))
+
+	var duplicateErr error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, duplicateErr = interceptor(ctx, req, info, func(context.Context, interface{}) (interface{}, error) {
+			t.Fatal("duplicate request should not reach the handler")
+			return nil, nil
+		})
+		return wrapperspb.String("created"), nil
+	}
+	_, err := interceptor(ctx, wrapperspb.String("req"), info, handler)
+	assert.NoError(t, err)
+	assert.EqualValues(t, codes.Aborted, status.Code(duplicateErr))
+}
+
+func TestRetryableErrorReleasesKey(t *testing.T) {
+	m := NewIdempotencyMiddleware(logger.NewLogger(true), NewMemoryStore())
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "key1"))
+
+	_, err := interceptor(ctx, wrapperspb.String("req"), info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "try again")
+	})
+	assert.EqualValues(t, codes.Unavailable, status.Code(err))
+
+	resp, err := interceptor(ctx, wrapperspb.String("req"), info, func(context.Context, interface{}) (interface{}, error) {
+		return wrapperspb.String("created"), nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "created", resp.(*wrapperspb.StringValue).GetValue())
+}
+
+func TestMemoryStoreSweepsExpiredKeys(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	_, reserved, err := store.Reserve(ctx, "expired", "hash", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	// the next sweep is due
+	store.nextSweep = time.Time{}
+	_, reserved, err = store.Reserve(ctx, "live", "hash", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Len(t, store.records, 1)
+	_, found := store.records["live"]
+	assert.True(t, found)
+}
+
+func TestAbandonedKeyExpiresAfterLockTimeout(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewIdempotencyMiddleware(logger.NewLogger(true), store, WithLockTimeout(50*time.Millisecond))
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "key1"))
+
+	// the handler panicked, the key was neither completed nor released
+	_, reserved, err := store.Reserve(ctx, storeKey(ctx, info.FullMethod, "key1"), "hash", time.Now().Add(50*time.Millisecond))
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	time.Sleep(60 * time.Millisecond)
+	resp, err := interceptor(ctx, wrapperspb.String("req"), info, func(context.Context, interface{}) (interface{}, error) {
+		return wrapperspb.String("created"), nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, "created", resp.(*wrapperspb.StringValue).GetValue())
+
+	// completed records are kept for the ttl
+	time.Sleep(60 * time.Millisecond)
+	calls := 0
+	_, err = interceptor(ctx, wrapperspb.String("req"), info, func(context.Context, interface{}) (interface{}, error) {
+		calls++
+		return wrapperspb.String("created"), nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, calls)
+}
+
+func TestNilResponseIsNotStored(t *testing.T) {
+	m := NewIdempotencyMiddleware(logger.NewLogger(true), NewMemoryStore())
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", "key1"))
+
+	calls := 0
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		calls++
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := interceptor(ctx, wrapperspb.String("req"), info, handler)
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	}
+	assert.EqualValues(t, 2, calls)
+}
@@ -0,0 +1,107 @@
+package idempotencymiddleware
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	storageerrors "github.com/sdinsure/agent/pkg/storage/errors"
+	storagepostgres "github.com/sdinsure/agent/pkg/storage/postgres"
+)
+
+type idempotencyRecord struct {
+	Key         string    `gorm:"column:key;primaryKey"`
+	RequestHash string    `gorm:"column:request_hash"`
+	Completed   bool      `gorm:"column:completed"`
+	Response    []byte    `gorm:"column:response"`
+	Status      []byte    `gorm:"column:status"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;index"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
+}
+
+func (i idempotencyRecord) TableName() string {
+	return "idempotency_records"
+}
+
+var (
+	_ Store = &PostgresStore{}
+)
+
+// PostgresStore shares records across replicas through postgres
+func NewPostgresStore(db *storagepostgres.PostgresDb) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+type PostgresStore struct {
+	db *storagepostgres.PostgresDb
+}
+
+func (p *PostgresStore) AutoMigrate() error {
+	tables := []interface{}{&idempotencyRecord{}}
+	if err := p.db.AutoMigrate(tables); err != nil {
+		return storageerrors.WrapStorageError(err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Reserve(ctx context.Context, key string, requestHash string, expiresAt time.Time) (*Record, bool, error) {
+	// expired keys can be reused
+	if err := p.db.With(ctx, "").
+		Where("key = ? AND expires_at < ?", key, time.Now()).
+		Delete(&idempotencyRecord{}).Error; err != nil {
+		return nil, false, storageerrors.WrapStorageError(err)
+	}
+
+	result := p.db.With(ctx, "").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&idempotencyRecord{
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   expiresAt,
+		})
+	if result.Error != nil {
+		return nil, false, storageerrors.WrapStorageError(result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	existing := &idempotencyRecord{}
+	if err := p.db.With(ctx, "").Where("key = ?", key).First(existing).Error; err != nil {
+		return nil, false, storageerrors.WrapStorageError(err)
+	}
+	return &Record{
+		RequestHash: existing.RequestHash,
+		Completed:   existing.Completed,
+		Response:    existing.Response,
+		Status:      existing.Status,
+	}, false, nil
+}
+
+func (p *PostgresStore) Complete(ctx context.Context, key string, record *Record, expiresAt time.Time) error {
+	err := p.db.With(ctx, "").
+		Model(&idempotencyRecord{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"completed":  record.Completed,
+			"response":   record.Response,
+			"status":     record.Status,
+			"expires_at": expiresAt,
+		}).Error
+	if err != nil {
+		return storageerrors.WrapStorageError(err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Release(ctx context.Context, key string) error {
+	err := p.db.With(ctx, "").
+		Where("key = ?", key).
+		Delete(&idempotencyRecord{}).Error
+	if err != nil {
+		return storageerrors.WrapStorageError(err)
+	}
+	return nil
+}
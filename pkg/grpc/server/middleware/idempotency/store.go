@@ -0,0 +1,152 @@
+package idempotencymiddleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Record is the stored outcome of the first request made with a key
+type Record struct {
+	RequestHash string
+	Completed   bool
+
+	// Response is the marshaled anypb.Any of the response message
+	Response []byte
+	// Status is the marshaled google.rpc.Status returned by the handler
+	Status []byte
+}
+
+type Store interface {
+	// Reserve marks key as in flight until expiresAt. if the key already exists,
+	// the existing record is returned and reserved is false.
+	Reserve(ctx context.Context, key string, requestHash string, expiresAt time.Time) (existing *Record, reserved bool, err error)
+
+	// Complete stores the final outcome of the reserved key, which is kept
+	// until expiresAt
+	Complete(ctx context.Context, key string, record *Record, expiresAt time.Time) error
+
+	// Release removes the key so it can be reserved again
+	Release(ctx context.Context, key string) error
+}
+
+func newCompletedRecord(requestHash string, resp interface{}, handlerErr error) (*Record, error) {
+	record := &Record{
+		RequestHash: requestHash,
+		Completed:   true,
+	}
+	if handlerErr != nil {
+		b, err := proto.Marshal(status.Convert(handlerErr).Proto())
+		if err != nil {
+			return nil, err
+		}
+		record.Status = b
+		return record, nil
+	}
+	msg, isProto := resp.(proto.Message)
+	if !isProto || msg == nil {
+		return nil, errors.New("idempotency: response is not a proto message")
+	}
+	anyResp, err := anypb.New(msg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := proto.Marshal(anyResp)
+	if err != nil {
+		return nil, err
+	}
+	record.Response = b
+	return record, nil
+}
+
+func unmarshalStatus(b []byte) (*status.Status, error) {
+	st := &spb.Status{}
+	if err := proto.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return status.FromProto(st), nil
+}
+
+var (
+	_ Store = &MemoryStore{}
+)
+
+// memorySweepInterval is how often Reserve removes expired records
+const memorySweepInterval = time.Minute
+
+// MemoryStore keeps records in process, which only works for a single replica.
+// expired records are swept by Reserve.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]memoryRecord),
+	}
+}
+
+type memoryRecord struct {
+	record    Record
+	expiresAt time.Time
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryRecord
+	nextSweep time.Time
+}
+
+func (m *MemoryStore) Reserve(ctx context.Context, key string, requestHash string, expiresAt time.Time) (*Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.After(m.nextSweep) {
+		m.sweepLocked(now)
+		m.nextSweep = now.Add(memorySweepInterval)
+	}
+	if existing, found := m.records[key]; found && now.Before(existing.expiresAt) {
+		record := existing.record
+		return &record, false, nil
+	}
+	m.records[key] = memoryRecord{
+		record:    Record{RequestHash: requestHash},
+		expiresAt: expiresAt,
+	}
+	return nil, true, nil
+}
+
+// sweepLocked removes records expired at now, keys which never come back
+// would be kept for the life of the process otherwise
+func (m *MemoryStore) sweepLocked(now time.Time) {
+	for key, record := range m.records {
+		if !now.Before(record.expiresAt) {
+			delete(m.records, key)
+		}
+	}
+}
+
+func (m *MemoryStore) Complete(ctx context.Context, key string, record *Record, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, found := m.records[key]
+	if !found {
+		return nil
+	}
+	existing.record = *record
+	existing.expiresAt = expiresAt
+	m.records[key] = existing
+	return nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
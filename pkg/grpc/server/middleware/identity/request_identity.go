@@ -3,38 +3,67 @@ package identitymiddleware
 import (
 	"context"
 
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
 	"github.com/sdinsure/agent/pkg/grpc/server/middleware"
+	sdinsuregrpcserverruntime "github.com/sdinsure/agent/pkg/grpc/server/runtime"
 	sdinsureruntime "github.com/sdinsure/agent/pkg/runtime"
-	"google.golang.org/grpc"
 )
 
 var (
 	_ middleware.ServerMiddleware = &RequestIdentityMiddleware{}
 )
 
+const (
+	// requestIdTag is the ctxtags key, which is written to every log with ctx
+	requestIdTag = "request_id"
+	// requestIdAttribute is the trace span attribute
+	requestIdAttribute = "request.id"
+)
+
 func NewRequestIdentityMiddleware() *RequestIdentityMiddleware {
 	return &RequestIdentityMiddleware{
 		uuidResolver: &sdinsureruntime.UUIDRequestIdentityResolver{},
 	}
 }
 
+// RequestIdentityMiddleware accepts the x-request-id from the caller (or
+// generates one), and propagates it to logs, the trace span and the response
+// header. it should be placed after the tag middleware.
 type RequestIdentityMiddleware struct {
 	uuidResolver sdinsureruntime.RequestIdentityResolver
 }
 
 func (r *RequestIdentityMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		return handler(r.uuidResolver.WithRequestID(ctx), req)
+		ctx, reqId := r.withRequestId(ctx)
+		// error is ignored as headers could have been sent
+		grpc.SetHeader(ctx, sdinsuregrpcserverruntime.RequestIdMetadata(reqId))
+		return handler(ctx, req)
 	}
 }
 
 func (r *RequestIdentityMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, reqId := r.withRequestId(stream.Context())
+		stream.SetHeader(sdinsuregrpcserverruntime.RequestIdMetadata(reqId))
 		wrappedStream := &middleware.ServerStreamWrapper{
-			Ctx:          r.uuidResolver.WithRequestID(stream.Context()),
+			Ctx:          ctx,
 			ServerStream: stream,
 		}
 
 		return handler(srv, wrappedStream)
 	}
 }
+
+func (r *RequestIdentityMiddleware) withRequestId(ctx context.Context) (context.Context, string) {
+	ctx = r.uuidResolver.WithRequestID(ctx)
+	reqId, _ := r.uuidResolver.RequestID(ctx)
+
+	grpc_ctxtags.Extract(ctx).Set(requestIdTag, reqId.String())
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(requestIdAttribute, reqId.String()))
+	return ctx, reqId.String()
+}
@@ -7,8 +7,10 @@ import (
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/sdinsure/agent/pkg/logger"
+	"github.com/sdinsure/agent/pkg/redact"
 )
 
 func NewLoggerMiddleware(l logger.Logger) *LoggerMiddleware {
@@ -46,23 +48,77 @@ func (l *LoggerMiddleware) StreamServerInterceptor() grpc.StreamServerIntercepto
 	return grpc_zap.StreamServerInterceptor(logger.GetUnderlyingZapLoggerOrDie(l.l), l.verboseOption())
 }
 
-func NewTagMiddlware() *TagMiddlware {
-	return &TagMiddlware{}
+type TagMiddlwareOptioner interface {
+	apply(*TagMiddlwareOptions)
 }
 
-type TagMiddlware struct{}
+type TagMiddlwareOptions struct {
+	redactor *redact.Redactor
+}
+
+type tagRedactor struct {
+	redactor *redact.Redactor
+}
+
+func (t tagRedactor) apply(o *TagMiddlwareOptions) {
+	o.redactor = t.redactor
+}
+
+// WithRedactor sets how sensitive request fields are masked, defaults to
+// redact.NewRedactor()
+func WithRedactor(redactor *redact.Redactor) tagRedactor {
+	return tagRedactor{redactor: redactor}
+}
+
+// NewTagMiddlware extracts request fields into ctxtags, which are logged by
+// grpc_zap and the logger. sensitive fields, including fields with the
+// debug_redact option, are masked before they are extracted.
+func NewTagMiddlware(optioners ...TagMiddlwareOptioner) *TagMiddlware {
+	o := &TagMiddlwareOptions{}
+	for _, optioner := range optioners {
+		optioner.apply(o)
+	}
+	if o.redactor == nil {
+		o.redactor = redact.NewRedactor()
+	}
+	return &TagMiddlware{redactor: o.redactor}
+}
+
+type TagMiddlware struct {
+	redactor *redact.Redactor
+}
 
 func (t *TagMiddlware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
-	return grpc_ctxtags.UnaryServerInterceptor(tagMiddlewareOption())
+	return grpc_ctxtags.UnaryServerInterceptor(t.tagMiddlewareOption())
 }
 
 func (t *TagMiddlware) StreamServerInterceptor() grpc.StreamServerInterceptor {
-	return grpc_ctxtags.StreamServerInterceptor(tagMiddlewareOption())
+	return grpc_ctxtags.StreamServerInterceptor(t.tagMiddlewareOption())
+}
+
+// requestFieldsExtractor is implemented by messages generated with field
+// extractors, see grpc_ctxtags.CodeGenRequestFieldExtractor
+type requestFieldsExtractor interface {
+	ExtractRequestFields(appendToMap map[string]interface{})
 }
 
-func tagMiddlewareOption() grpc_ctxtags.Option {
+func (t *TagMiddlware) tagMiddlewareOption() grpc_ctxtags.Option {
 	return grpc_ctxtags.WithFieldExtractor(
-		grpc_ctxtags.CodeGenRequestFieldExtractor,
+		func(fullMethod string, req interface{}) map[string]interface{} {
+			if _, isExtractor := req.(requestFieldsExtractor); !isExtractor {
+				return nil
+			}
+			if msg, isMessage := req.(proto.Message); isMessage {
+				// masks fields by descriptor, e.g. debug_redact, which are
+				// not known by name once extracted
+				req = t.redactor.Message(msg)
+			}
+			fields := grpc_ctxtags.CodeGenRequestFieldExtractor(fullMethod, req)
+			if fields == nil {
+				return nil
+			}
+			return t.redactor.Fields(fields)
+		},
 	)
 
 }
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/sdinsure/agent/pkg/grpc/server/middleware"
 	"github.com/sdinsure/agent/pkg/metrics"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/status"
@@ -12,6 +13,7 @@ import (
 
 var serverNamespace = metrics.NewTypeNamespace("rpc_server")
 var subsystem = metrics.NewTypeSubsystem("requests")
+var streamSubsystem = metrics.NewTypeSubsystem("streams")
 
 var (
 	metricServerReqDur = metrics.NewTimeDurationHistogramVec(
@@ -26,6 +28,31 @@ var (
 		metrics.NewTypeMetricName("code_total"),
 		"method", "rpccode",
 	)
+
+	metricServerStreamDur = metrics.NewTimeDurationHistogramVec(
+		serverNamespace,
+		streamSubsystem,
+		metrics.NewTypeMetricName("duration_ms"),
+		"method", "rpccode",
+	)
+	metricServerStreamMsgReceivedTotal = metrics.NewCounterVec(
+		serverNamespace,
+		streamSubsystem,
+		metrics.NewTypeMetricName("msg_received_total"),
+		"method",
+	)
+	metricServerStreamMsgSentTotal = metrics.NewCounterVec(
+		serverNamespace,
+		streamSubsystem,
+		metrics.NewTypeMetricName("msg_sent_total"),
+		"method",
+	)
+	metricServerStreamActive = metrics.NewUpDownCounterVec(
+		serverNamespace,
+		streamSubsystem,
+		metrics.NewTypeMetricName("active"),
+		"method",
+	)
 )
 
 func NewMetricMiddleware() *MetricMiddleware {
@@ -47,9 +74,45 @@ func (m *MetricMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor
 
 func (m *MetricMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		// no request duration for streaming
-		err := handler(srv, stream)
-		metricServerReqCodeTotal.Inc(context.Background(), info.FullMethod, strconv.Itoa(int(status.Code(err))))
+		ctx := stream.Context()
+		metricServerStreamActive.Add(ctx, 1, info.FullMethod)
+		defer metricServerStreamActive.Add(ctx, -1, info.FullMethod)
+
+		startTime := time.Now()
+		wrappedStream := &metricServerStream{
+			ServerStreamWrapper: &middleware.ServerStreamWrapper{
+				Ctx:          ctx,
+				ServerStream: stream,
+			},
+			method: info.FullMethod,
+		}
+		err := handler(srv, wrappedStream)
+		code := strconv.Itoa(int(status.Code(err)))
+		metricServerStreamDur.Observe(ctx, time.Since(startTime), info.FullMethod, code)
+		metricServerReqCodeTotal.Inc(ctx, info.FullMethod, code)
 		return err
 	}
 }
+
+// metricServerStream counts messages of a stream
+type metricServerStream struct {
+	*middleware.ServerStreamWrapper
+
+	method string
+}
+
+func (m *metricServerStream) SendMsg(msg interface{}) error {
+	err := m.ServerStreamWrapper.SendMsg(msg)
+	if err == nil {
+		metricServerStreamMsgSentTotal.Inc(m.Context(), m.method)
+	}
+	return err
+}
+
+func (m *metricServerStream) RecvMsg(msg interface{}) error {
+	err := m.ServerStreamWrapper.RecvMsg(msg)
+	if err == nil {
+		metricServerStreamMsgReceivedTotal.Inc(m.Context(), m.method)
+	}
+	return err
+}
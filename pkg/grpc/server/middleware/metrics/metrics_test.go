@@ -0,0 +1,85 @@
+package metricmiddleware
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeServerStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (f *fakeServerStream) RecvMsg(m interface{}) error {
+	if f.received == 2 {
+		return io.EOF
+	}
+	f.received++
+	return nil
+}
+
+// gatherValues returns values of metrics with method label of method, by metric name
+func gatherValues(t *testing.T, method string) map[string]float64 {
+	families, err := prom.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() != "method" || label.GetValue() != method {
+					continue
+				}
+				switch {
+				case m.GetCounter() != nil:
+					values[family.GetName()] = m.GetCounter().GetValue()
+				case m.GetGauge() != nil:
+					values[family.GetName()] = m.GetGauge().GetValue()
+				case m.GetHistogram() != nil:
+					values[family.GetName()] = float64(m.GetHistogram().GetSampleCount())
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	method := "/test.Service/Chat"
+	var activeDuringStream float64
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		activeDuringStream = gatherValues(t, method)["rpc_server_streams_active"]
+		for {
+			if err := stream.RecvMsg(nil); err != nil {
+				break
+			}
+			if err := stream.SendMsg(nil); err != nil {
+				return err
+			}
+		}
+		return stream.SendMsg(nil)
+	}
+
+	err := NewMetricMiddleware().StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: method}, handler)
+	assert.NoError(t, err)
+
+	values := gatherValues(t, method)
+	assert.EqualValues(t, 1, activeDuringStream)
+	assert.EqualValues(t, 0, values["rpc_server_streams_active"])
+	assert.EqualValues(t, 2, values["rpc_server_streams_msg_received_total"])
+	assert.EqualValues(t, 3, values["rpc_server_streams_msg_sent_total"])
+	assert.EqualValues(t, 1, values["rpc_server_streams_duration_ms"])
+}
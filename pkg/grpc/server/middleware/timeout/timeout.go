@@ -0,0 +1,127 @@
+package timeoutmiddleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	sderrors "github.com/sdinsure/agent/pkg/errors"
+	"github.com/sdinsure/agent/pkg/grpc/server/middleware"
+)
+
+var (
+	_ middleware.ServerMiddleware = &TimeoutMiddleware{}
+)
+
+type TimeoutMiddlewareOptioner interface {
+	apply(o *TimeoutMiddlewareOptions)
+}
+
+type TimeoutMiddlewareOptions struct {
+	methodTimeouts map[string]time.Duration
+}
+
+type methodTimeout struct {
+	fullMethod string
+	timeout    time.Duration
+}
+
+func (m methodTimeout) apply(o *TimeoutMiddlewareOptions) {
+	o.methodTimeouts[m.fullMethod] = m.timeout
+}
+
+// WithMethodTimeout overrides the default timeout for a grpc full method,
+// e.g. /example.api.HelloService/SayHello. 0 disables the deadline for it.
+func WithMethodTimeout(fullMethod string, timeout time.Duration) TimeoutMiddlewareOptioner {
+	return methodTimeout{fullMethod: fullMethod, timeout: timeout}
+}
+
+func newOptions(opts ...TimeoutMiddlewareOptioner) *TimeoutMiddlewareOptions {
+	o := &TimeoutMiddlewareOptions{
+		methodTimeouts: make(map[string]time.Duration),
+	}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	return o
+}
+
+// NewTimeoutMiddleware applies defaultTimeout (or the per method override) as
+// the handler deadline. a shorter deadline sent by the client via grpc-timeout
+// is kept, since context.WithTimeout never extends its parent.
+func NewTimeoutMiddleware(defaultTimeout time.Duration, optioners ...TimeoutMiddlewareOptioner) *TimeoutMiddleware {
+	return &TimeoutMiddleware{
+		defaultTimeout: defaultTimeout,
+		opt:            newOptions(optioners...),
+	}
+}
+
+type TimeoutMiddleware struct {
+	defaultTimeout time.Duration
+	opt            *TimeoutMiddlewareOptions
+}
+
+func (t *TimeoutMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		timeout := t.timeout(info.FullMethod)
+		if timeout <= 0 {
+			resp, err := handler(ctx, req)
+			return resp, convertDeadlineError(ctx, err)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// the handler runs inline, inner middlewares (e.g. recovery or
+		// idempotency) must finish before the call returns. handlers are
+		// expected to honor ctx.
+		resp, err := handler(ctx, req)
+		return resp, convertDeadlineError(ctx, err)
+	}
+}
+
+func (t *TimeoutMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		timeout := t.timeout(info.FullMethod)
+		if timeout <= 0 {
+			return convertDeadlineError(stream.Context(), handler(srv, stream))
+		}
+		ctx, cancel := context.WithTimeout(stream.Context(), timeout)
+		defer cancel()
+
+		wrappedStream := &middleware.ServerStreamWrapper{
+			Ctx:          ctx,
+			ServerStream: stream,
+		}
+		return convertDeadlineError(ctx, handler(srv, wrappedStream))
+	}
+}
+
+func (t *TimeoutMiddleware) timeout(fullMethod string) time.Duration {
+	if timeout, found := t.opt.methodTimeouts[fullMethod]; found {
+		return timeout
+	}
+	return t.defaultTimeout
+}
+
+// convertDeadlineError turns a bare context.DeadlineExceeded returned by the
+// handler into a timeout error, otherwise grpc reports it as Unknown
+func convertDeadlineError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return contextError(ctx)
+	}
+	return err
+}
+
+func contextError(ctx context.Context) error {
+	if err := sderrors.NewContextError(ctx); err != nil {
+		return err
+	}
+	// canceled by the client
+	return status.FromContextError(ctx.Err()).Err()
+}
@@ -0,0 +1,55 @@
+package timeoutmiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHandlerExceedingDeadline(t *testing.T) {
+	m := NewTimeoutMiddleware(50 * time.Millisecond)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Slow"}
+
+	// handler ignoring its context finishes before the call returns
+	done := false
+	_, err := m.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		done = true
+		return nil, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	// handler returning the bare context error is no longer Unknown
+	_, err = m.UnaryServerInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.EqualValues(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestMethodTimeoutAndClientDeadline(t *testing.T) {
+	m := NewTimeoutMiddleware(time.Hour, WithMethodTimeout("/test.Service/Short", time.Minute))
+
+	var deadline time.Time
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		deadline, _ = ctx.Deadline()
+		return nil, nil
+	}
+
+	_, err := m.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Short"}, handler)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// a shorter deadline from the client is honored
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = m.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Other"}, handler)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+}
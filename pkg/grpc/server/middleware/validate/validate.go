@@ -0,0 +1,106 @@
+package validatemiddleware
+
+import (
+	"context"
+	"errors"
+
+	"buf.build/go/protovalidate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	sderrors "github.com/sdinsure/agent/pkg/errors"
+	"github.com/sdinsure/agent/pkg/grpc/server/middleware"
+)
+
+var (
+	_ middleware.ServerMiddleware = &ValidateMiddleware{}
+)
+
+// NewValidateMiddleware validates incoming request messages against their
+// buf.validate annotations before the handler is invoked.
+// a nil validator falls back to protovalidate.GlobalValidator
+func NewValidateMiddleware(v protovalidate.Validator) *ValidateMiddleware {
+	if v == nil {
+		v = protovalidate.GlobalValidator
+	}
+	return &ValidateMiddleware{validator: v}
+}
+
+type ValidateMiddleware struct {
+	validator protovalidate.Validator
+}
+
+func (v *ValidateMiddleware) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := v.validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (v *ValidateMiddleware) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrappedStream := &validateServerStream{
+			ServerStreamWrapper: &middleware.ServerStreamWrapper{
+				Ctx:          stream.Context(),
+				ServerStream: stream,
+			},
+			v: v,
+		}
+		return handler(srv, wrappedStream)
+	}
+}
+
+func (v *ValidateMiddleware) validate(req interface{}) error {
+	msg, isProto := req.(proto.Message)
+	if !isProto {
+		// nothing we can validate
+		return nil
+	}
+	err := v.validator.Validate(msg)
+	if err == nil {
+		return nil
+	}
+	var validationErr *protovalidate.ValidationError
+	if errors.As(err, &validationErr) {
+		return newInvalidArgumentStatus(validationErr).Err()
+	}
+	// compilation or runtime errors are problems of the rules, not the request
+	return sderrors.NewInternalError(err)
+}
+
+// newInvalidArgumentStatus converts violations into InvalidArgument with
+// BadRequest details, which grpc-gateway renders into the "details" field
+func newInvalidArgumentStatus(validationErr *protovalidate.ValidationError) *status.Status {
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range validationErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       protovalidate.FieldPathString(violation.Proto.GetField()),
+			Description: violation.Proto.GetMessage(),
+		})
+	}
+	st := status.New(codes.InvalidArgument, validationErr.Error())
+	withDetails, err := st.WithDetails(badRequest)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// validateServerStream validates every message received from the client
+type validateServerStream struct {
+	*middleware.ServerStreamWrapper
+
+	v *ValidateMiddleware
+}
+
+func (s *validateServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStreamWrapper.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.v.validate(m)
+}
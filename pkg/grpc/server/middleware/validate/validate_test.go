@@ -0,0 +1,45 @@
+package validatemiddleware
+
+import (
+	"testing"
+
+	"buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"buf.build/go/protovalidate"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestViolationsToBadRequest(t *testing.T) {
+	validationErr := &protovalidate.ValidationError{
+		Violations: []*protovalidate.Violation{
+			{
+				Proto: &validate.Violation{
+					Field: &validate.FieldPath{
+						Elements: []*validate.FieldPathElement{
+							{FieldName: proto.String("greeting")},
+						},
+					},
+					RuleId:  proto.String("string.min_len"),
+					Message: proto.String("value length must be at least 1 characters"),
+				},
+			},
+		},
+	}
+
+	st := newInvalidArgumentStatus(validationErr)
+	assert.EqualValues(t, codes.InvalidArgument, st.Code())
+	assert.Len(t, st.Details(), 1)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	assert.True(t, ok)
+	assert.Len(t, badRequest.GetFieldViolations(), 1)
+	assert.EqualValues(t, "greeting", badRequest.GetFieldViolations()[0].GetField())
+	assert.EqualValues(t, "value length must be at least 1 characters", badRequest.GetFieldViolations()[0].GetDescription())
+}
+
+func TestNonProtoRequestIsSkipped(t *testing.T) {
+	v := NewValidateMiddleware(nil)
+	assert.NoError(t, v.validate(struct{}{}))
+}
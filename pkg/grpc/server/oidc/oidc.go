@@ -0,0 +1,394 @@
+// Package oidc logs browsers in with the OpenID Connect authorization code flow
+// with PKCE. the token is kept in the Authorization cookie, which is promoted
+// to metadata by metadata.HttpCookiesToGrpcMetadata and verified by the authn
+// middleware, or in a session of session.Manager.
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+
+	authnmiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/authn"
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+	"github.com/sdinsure/agent/pkg/grpc/server/session"
+)
+
+var (
+	ErrState = errors.New("oidc: state mismatch")
+	ErrNonce = errors.New("oidc: nonce mismatch")
+)
+
+const (
+	// authorizationCookie is promoted to authorization by
+	// metadata.HttpCookiesToGrpcMetadata
+	authorizationCookie = "Authorization"
+	// stateCookie keeps state, nonce and the pkce verifier across the
+	// redirect to the provider
+	stateCookie    = "oidc_state"
+	stateCookieAge = 10 * time.Minute
+	// idTokenHintCookie keeps the id token for the id_token_hint of the end
+	// session endpoint, when the access token is stored without sessions
+	idTokenHintCookie = "oidc_id_token"
+	// maxIdTokenHintBytes keeps idTokenHintCookie under the 4KB limit of
+	// cookies, larger hints are not kept
+	maxIdTokenHintBytes = 3072
+	// idTokenHintValue keeps the id token in Session.Values, when the access
+	// token is stored in sessions
+	idTokenHintValue = "id_token"
+)
+
+type Config struct {
+	// Issuer is the issuer url, the provider is discovered from
+	// <Issuer>/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the url of the callback route, registered at the provider
+	RedirectURL string
+	// Scopes defaults to openid, profile and email
+	Scopes []string
+	// PostLogoutRedirectURL is where browsers land after logout, defaults to /
+	PostLogoutRedirectURL string
+
+	// ClaimParser verifies the token before it is stored, it should be the
+	// parser of the authn middleware
+	ClaimParser authnmiddleware.ClaimParser
+	// UseAccessToken stores the access token instead of the id token
+	UseAccessToken bool
+	// Sessions stores the token in an encrypted session instead of the
+	// Authorization cookie, id tokens may exceed the 4KB limit of cookies
+	Sessions *session.Manager
+
+	// Insecure drops the Secure attribute of cookies, e.g. for http on localhost
+	Insecure bool
+	// HTTPClient talks to the provider, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// discovery is the subset of the provider metadata used by the flow, see
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+type Provider struct {
+	cfg Config
+
+	mu        sync.Mutex
+	discovery *discovery
+}
+
+func New(cfg Config) (*Provider, error) {
+	if len(cfg.Issuer) == 0 || len(cfg.ClientID) == 0 || len(cfg.RedirectURL) == 0 {
+		return nil, errors.New("oidc: issuer, client id and redirect url are required")
+	}
+	if cfg.ClaimParser == nil {
+		return nil, errors.New("oidc: claim parser is required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if len(cfg.PostLogoutRedirectURL) == 0 {
+		cfg.PostLogoutRedirectURL = "/"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Provider{cfg: cfg}, nil
+}
+
+// discover fetches the provider metadata once, failures are retried by the
+// next login
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed, err:%w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery failed, status:%d", resp.StatusCode)
+	}
+	d := &discovery{}
+	if err := json.NewDecoder(resp.Body).Decode(d); err != nil {
+		return nil, fmt.Errorf("oidc: invalid discovery, err:%w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc: issuer mismatch, want:%s, got:%s", p.cfg.Issuer, d.Issuer)
+	}
+	p.discovery = d
+	return d, nil
+}
+
+func (p *Provider) oauth2Config(d *discovery) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  d.AuthorizationEndpoint,
+			TokenURL: d.TokenEndpoint,
+		},
+		RedirectURL: p.cfg.RedirectURL,
+		Scopes:      p.cfg.Scopes,
+	}
+}
+
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+}
+
+// LoginHandler redirects to the provider. browsers return to the "redirect"
+// query parameter after the callback, which must be a local path.
+func (p *Provider) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := p.discover(r.Context())
+		if err != nil {
+			runtime.WriteHttpError(w, codes.Unavailable, err)
+			return
+		}
+		s := loginState{
+			State:    oauth2.GenerateVerifier(),
+			Nonce:    oauth2.GenerateVerifier(),
+			Verifier: oauth2.GenerateVerifier(),
+			ReturnTo: localPath(r.URL.Query().Get("redirect")),
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			runtime.WriteHttpError(w, codes.Internal, err)
+			return
+		}
+		http.SetCookie(w, p.cookie(stateCookie, base64.RawURLEncoding.EncodeToString(b), time.Now().Add(stateCookieAge)))
+		authURL := p.oauth2Config(d).AuthCodeURL(s.State,
+			oauth2.S256ChallengeOption(s.Verifier),
+			oauth2.SetAuthURLParam("nonce", s.Nonce),
+		)
+		http.Redirect(w, r, authURL, http.StatusFound)
+	})
+}
+
+// CallbackHandler exchanges the code for tokens, verifies the token by the
+// claim parser, stores it and redirects to the path requested at login
+func (p *Provider) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if errCode := query.Get("error"); len(errCode) > 0 {
+			runtime.WriteHttpError(w, codes.Unauthenticated, fmt.Errorf("oidc: %s: %s", errCode, query.Get("error_description")))
+			return
+		}
+		s, err := readState(r)
+		if err != nil || len(query.Get("state")) == 0 || query.Get("state") != s.State {
+			runtime.WriteHttpError(w, codes.PermissionDenied, ErrState)
+			return
+		}
+		// the state is single use
+		http.SetCookie(w, p.cookie(stateCookie, "", time.Unix(0, 0)))
+
+		d, err := p.discover(r.Context())
+		if err != nil {
+			runtime.WriteHttpError(w, codes.Unavailable, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), oauth2.HTTPClient, p.cfg.HTTPClient)
+		token, err := p.oauth2Config(d).Exchange(ctx, query.Get("code"), oauth2.VerifierOption(s.Verifier))
+		if err != nil {
+			runtime.WriteHttpError(w, codes.Unauthenticated, fmt.Errorf("oidc: exchange failed, err:%w", err))
+			return
+		}
+		idToken, _ := token.Extra("id_token").(string)
+		if len(idToken) == 0 {
+			runtime.WriteHttpError(w, codes.Unauthenticated, errors.New("oidc: id_token is missing"))
+			return
+		}
+		if err := checkNonce(idToken, s.Nonce); err != nil {
+			runtime.WriteHttpError(w, codes.Unauthenticated, err)
+			return
+		}
+		stored := idToken
+		if p.cfg.UseAccessToken {
+			stored = token.AccessToken
+		}
+		claims, err := p.cfg.ClaimParser.ParseClaim(r.Context(), stored)
+		if err != nil {
+			runtime.WriteHttpError(w, codes.Unauthenticated, err)
+			return
+		}
+		expiresAt := token.Expiry
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			expiresAt = exp.Time
+		}
+		if err := p.store(w, stored, idToken, expiresAt); err != nil {
+			runtime.WriteHttpError(w, codes.Internal, err)
+			return
+		}
+		http.Redirect(w, r, s.ReturnTo, http.StatusFound)
+	})
+}
+
+// LogoutHandler clears the token, and redirects to the end session endpoint
+// of the provider if there is one, otherwise to PostLogoutRedirectURL. it
+// requires POST, and the csrf check of sessions when Sessions is set.
+func (p *Provider) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			runtime.WriteHttpError(w, codes.Unimplemented, errors.New("oidc: logout requires POST"))
+			return
+		}
+		idTokenHint, err := p.idTokenHint(r)
+		if err != nil {
+			runtime.WriteHttpError(w, codes.PermissionDenied, err)
+			return
+		}
+		if p.cfg.Sessions != nil {
+			p.cfg.Sessions.Clear(w)
+		} else {
+			http.SetCookie(w, p.cookie(authorizationCookie, "", time.Unix(0, 0)))
+			if p.cfg.UseAccessToken {
+				http.SetCookie(w, p.cookie(idTokenHintCookie, "", time.Unix(0, 0)))
+			}
+		}
+
+		redirect := p.cfg.PostLogoutRedirectURL
+		if d, err := p.discover(r.Context()); err == nil && len(d.EndSessionEndpoint) > 0 {
+			endSession, err := url.Parse(d.EndSessionEndpoint)
+			if err == nil {
+				values := endSession.Query()
+				values.Set("client_id", p.cfg.ClientID)
+				values.Set("post_logout_redirect_uri", p.cfg.PostLogoutRedirectURL)
+				if len(idTokenHint) > 0 {
+					values.Set("id_token_hint", idTokenHint)
+				}
+				endSession.RawQuery = values.Encode()
+				redirect = endSession.String()
+			}
+		}
+		// the browser follows with GET
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+	})
+}
+
+// store keeps token for the authn middleware, and the id token for logout at
+// the provider unless it is the stored token already
+func (p *Provider) store(w http.ResponseWriter, token string, idToken string, expiresAt time.Time) error {
+	if p.cfg.Sessions != nil {
+		s := &session.Session{Token: token, ExpiresAt: expiresAt}
+		if p.cfg.UseAccessToken {
+			s.Values = map[string]string{idTokenHintValue: idToken}
+		}
+		return p.cfg.Sessions.Save(w, s)
+	}
+	http.SetCookie(w, p.cookie(authorizationCookie, "Bearer "+token, expiresAt))
+	if p.cfg.UseAccessToken && len(idToken) <= maxIdTokenHintBytes {
+		http.SetCookie(w, p.cookie(idTokenHintCookie, idToken, expiresAt))
+	}
+	return nil
+}
+
+// idTokenHint returns the id token kept by store, sessions must pass the csrf
+// check
+func (p *Provider) idTokenHint(r *http.Request) (string, error) {
+	if p.cfg.Sessions != nil {
+		s, err := p.cfg.Sessions.Load(r)
+		if err != nil {
+			// nothing to protect
+			return "", nil
+		}
+		if err := p.cfg.Sessions.CheckCSRF(r, s); err != nil {
+			return "", err
+		}
+		if p.cfg.UseAccessToken {
+			return s.Values[idTokenHintValue], nil
+		}
+		return s.Token, nil
+	}
+	if p.cfg.UseAccessToken {
+		if cookie, err := r.Cookie(idTokenHintCookie); err == nil {
+			return cookie.Value, nil
+		}
+		return "", nil
+	}
+	if cookie, err := r.Cookie(authorizationCookie); err == nil {
+		return strings.TrimPrefix(cookie.Value, "Bearer "), nil
+	}
+	return "", nil
+}
+
+func (p *Provider) cookie(name, value string, expiresAt time.Time) *http.Cookie {
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   maxAge,
+		Secure:   !p.cfg.Insecure,
+		HttpOnly: true,
+		// lax cookies are sent on the top level redirect from the provider
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func readState(r *http.Request) (*loginState, error) {
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+	s := &loginState{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkNonce compares the nonce claim of the id token, the signature is
+// verified by the claim parser
+func checkNonce(idToken string, nonce string) error {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		return fmt.Errorf("oidc: invalid id_token, err:%w", err)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return ErrNonce
+	}
+	return nil
+}
+
+// localPath returns path if it stays on this host, otherwise /
+func localPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+
+	grpcmetadata "github.com/sdinsure/agent/pkg/grpc/server/metadata"
+	"github.com/sdinsure/agent/pkg/grpc/server/session"
+)
+
+var signingKey = []byte("fake-idp-key")
+
+type hmacClaimParser struct{}
+
+func (hmacClaimParser) ParseClaim(ctx context.Context, token string) (jwt.Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return signingKey, nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	return claims, err
+}
+
+// fakeIdP is an oidc provider which authorizes every request
+type fakeIdP struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	challenge string
+	nonce     string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	idp := &fakeIdP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			EndSessionEndpoint:    idp.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+		idp.mu.Lock()
+		idp.challenge, idp.nonce = query.Get("code_challenge"), query.Get("nonce")
+		idp.mu.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?code=code&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		if r.FormValue("code") != "code" || oauth2.S256ChallengeFromVerifier(r.FormValue("code_verifier")) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":   idp.URL,
+			"sub":   "user",
+			"aud":   "client",
+			"nonce": idp.nonce,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}).SignedString(signingKey)
+		accessToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss": idp.URL,
+			"sub": "user",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(signingKey)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func newTestProvider(t *testing.T, idp *fakeIdP, sessions *session.Manager) *Provider {
+	p, err := New(Config{
+		Issuer:         idp.URL,
+		ClientID:       "client",
+		RedirectURL:    "https://app.example.com/auth/callback",
+		ClaimParser:    hmacClaimParser{},
+		Sessions:       sessions,
+		UseAccessToken: sessions != nil,
+	})
+	assert.NoError(t, err)
+	return p
+}
+
+// authorize follows the login redirect to the idp, and returns the callback
+// request with the cookies set by login
+func authorize(t *testing.T, p *Provider, idp *fakeIdP, state string) *http.Request {
+	w := httptest.NewRecorder()
+	p.LoginHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/login?redirect=/projects", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	if len(state) > 0 {
+		query := callback.Query()
+		query.Set("state", state)
+		callback.RawQuery = query.Encode()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := newTestProvider(t, idp, nil)
+
+	w := httptest.NewRecorder()
+	p.CallbackHandler().ServeHTTP(w, authorize(t, p, idp, ""))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/projects", w.Header().Get("Location"))
+
+	// the next request authenticates by the cookie
+	req := httptest.NewRequest(http.MethodGet, "/v1/projects", nil)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge > 0 {
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Secure)
+			req.AddCookie(cookie)
+		}
+	}
+	values := grpcmetadata.HttpCookiesToGrpcMetadata(context.Background(), req).Get("authorization")
+	assert.Len(t, values, 1)
+	token, found := strings.CutPrefix(values[0], "Bearer ")
+	assert.True(t, found)
+	_, err := hmacClaimParser{}.ParseClaim(context.Background(), token)
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	p.LogoutHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	req.Method = http.MethodPost
+	w = httptest.NewRecorder()
+	p.LogoutHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	logout, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, idp.URL+"/logout", logout.Scheme+"://"+logout.Host+logout.Path)
+	assert.Equal(t, token, logout.Query().Get("id_token_hint"))
+	for _, cookie := range w.Result().Cookies() {
+		assert.Empty(t, cookie.Value)
+	}
+}
+
+func TestLoginFlowSessions(t *testing.T) {
+	idp := newFakeIdP(t)
+	sessions, err := session.NewManager(session.Config{Keys: [][]byte{bytes.Repeat([]byte{1}, 32)}})
+	assert.NoError(t, err)
+	p := newTestProvider(t, idp, sessions)
+
+	w := httptest.NewRecorder()
+	p.CallbackHandler().ServeHTTP(w, authorize(t, p, idp, ""))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	// the id token is kept in the session only
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	var csrf string
+	for _, cookie := range w.Result().Cookies() {
+		assert.NotEqual(t, idTokenHintCookie, cookie.Name)
+		if cookie.MaxAge > 0 {
+			req.AddCookie(cookie)
+		}
+		if cookie.Name == "csrf_token" {
+			csrf = cookie.Value
+		}
+	}
+	s, err := sessions.Load(req)
+	assert.NoError(t, err)
+	assert.NotEqual(t, s.Token, s.Values[idTokenHintValue])
+
+	w = httptest.NewRecorder()
+	p.LogoutHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req.Header.Set("X-CSRF-Token", csrf)
+	w = httptest.NewRecorder()
+	p.LogoutHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	logout, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, s.Values[idTokenHintValue], logout.Query().Get("id_token_hint"))
+	assert.NotEmpty(t, logout.Query().Get("id_token_hint"))
+}
+
+func TestCallbackStateMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := newTestProvider(t, idp, nil)
+
+	w := httptest.NewRecorder()
+	p.CallbackHandler().ServeHTTP(w, authorize(t, p, idp, "forged"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestLocalPath(t *testing.T) {
+	assert.Equal(t, "/projects?id=1", localPath("/projects?id=1"))
+	assert.Equal(t, "/", localPath("https://evil.example.com"))
+	assert.Equal(t, "/", localPath("//evil.example.com"))
+	assert.Equal(t, "/", localPath("/\\evil.example.com"))
+	assert.Equal(t, "/", localPath(""))
+}
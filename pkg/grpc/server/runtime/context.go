@@ -5,23 +5,19 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+
+	"github.com/sdinsure/agent/pkg/logger/ctxfields"
 )
 
-type subInfo struct{}
 type claimInfo struct{}
 type keyInfo struct{}
-type requestId struct{}
 
 func WithSubInfo(ctx context.Context, subject string) context.Context {
-	return context.WithValue(ctx, subInfo{}, subject)
+	return ctxfields.WithSubject(ctx, subject)
 }
 
 func SubInfo(ctx context.Context) (string, bool) {
-	info := ctx.Value(subInfo{})
-	if _, castable := info.(string); castable {
-		return info.(string), true
-	}
-	return "", false
+	return ctxfields.Subject(ctx)
 }
 
 func WithClaimInfo(ctx context.Context, claims jwt.Claims) context.Context {
@@ -48,15 +44,43 @@ func KeyInfo(ctx context.Context) (string, bool) {
 	return "", false
 }
 
+// WithRequestId ensures ctx carries a request id. an id already in ctx is
+// kept, then the x-request-id from incoming metadata, otherwise a new one is
+// generated.
 func WithRequestId(ctx context.Context) context.Context {
-	reqId := uuid.NewString()
-	return context.WithValue(ctx, requestId{}, reqId)
+	if _, found := RequestId(ctx); found {
+		return ctx
+	}
+	reqId, _ := getMetaValueFromCtx(ctx, requestIdMetadata)
+	if !IsValidRequestId(reqId) {
+		reqId = NewRequestId()
+	}
+	return ctxfields.WithRequestId(ctx, reqId)
 }
 
-func RequestId(ctx context.Context) (string, bool) {
-	info := ctx.Value(requestId{})
-	if _, castable := info.(string); castable {
-		return info.(string), true
+// WithGivenRequestId sets reqId as the request id of ctx
+func WithGivenRequestId(ctx context.Context, reqId string) context.Context {
+	return ctxfields.WithRequestId(ctx, reqId)
+}
+
+func NewRequestId() string {
+	return uuid.NewString()
+}
+
+// IsValidRequestId rejects empty, oversized or non-printable ids sent by
+// clients, so they can be written to logs and headers as is
+func IsValidRequestId(reqId string) bool {
+	if len(reqId) == 0 || len(reqId) > 128 {
+		return false
 	}
-	return "", false
+	for _, r := range reqId {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func RequestId(ctx context.Context) (string, bool) {
+	return ctxfields.RequestId(ctx)
 }
@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithRequestId(t *testing.T) {
+	// inbound id from metadata is accepted
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc-123"))
+	ctx = WithRequestId(ctx)
+	reqId, found := RequestId(ctx)
+	assert.True(t, found)
+	assert.EqualValues(t, "abc-123", reqId)
+
+	// existing id is kept
+	reqId, _ = RequestId(WithRequestId(ctx))
+	assert.EqualValues(t, "abc-123", reqId)
+
+	// invalid inbound id is replaced
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "bad id\n"))
+	reqId, found = RequestId(WithRequestId(ctx))
+	assert.True(t, found)
+	assert.NotEqualValues(t, "bad id\n", reqId)
+	assert.True(t, IsValidRequestId(reqId))
+
+	// generated without metadata
+	reqId, found = RequestId(WithRequestId(context.Background()))
+	assert.True(t, found)
+	assert.NotEmpty(t, reqId)
+}
+
+func TestAppendRequestIdToOutgoingContext(t *testing.T) {
+	ctx := AppendRequestIdToOutgoingContext(WithGivenRequestId(context.Background(), "abc-123"))
+	ctx = AppendRequestIdToOutgoingContext(ctx)
+	md, found := metadata.FromOutgoingContext(ctx)
+	assert.True(t, found)
+	assert.EqualValues(t, []string{"abc-123"}, md.Get("x-request-id"))
+}
@@ -14,13 +14,26 @@ var (
 	httpPathPattern string = "http-path-pattern"
 	grpcMethod      string = "grpc-method"
 	remoteAddr      string = "remote-addr"
+	idempotencyKey  string = "idempotency-key"
+
+	requestIdMetadata string = "x-request-id"
+	subjectMetadata   string = "x-subject"
 )
 
+// RequestIdHeader is the http header carrying the request id
+const RequestIdHeader = "X-Request-Id"
+
 func ForwardHttpToMetadata(ctx context.Context, r *http.Request) metadata.MD {
 	md := make(map[string]string)
 	md[httpVerb] = r.Method
 	md[httpPath] = r.URL.Path
 	md[remoteAddr] = r.RemoteAddr
+	if reqId := r.Header.Get(RequestIdHeader); len(reqId) > 0 {
+		md[requestIdMetadata] = reqId
+	}
+	if key := r.Header.Get("Idempotency-Key"); len(key) > 0 {
+		md[idempotencyKey] = key
+	}
 	if method, ok := runtime.RPCMethod(ctx); ok {
 		md[grpcMethod] = method
 	}
@@ -50,6 +63,45 @@ func RemoteAddr(ctx context.Context) (string, bool) {
 	return getMetaValueFromCtx(ctx, remoteAddr)
 }
 
+// IdempotencyKey returns the key forwarded from the http Idempotency-Key header,
+// or the idempotency-key metadata sent directly by grpc clients
+func IdempotencyKey(ctx context.Context) (string, bool) {
+	return getMetaValueFromCtx(ctx, idempotencyKey)
+}
+
+// AppendRequestIdToOutgoingContext forwards the request id of ctx to the
+// outgoing grpc metadata
+func AppendRequestIdToOutgoingContext(ctx context.Context) context.Context {
+	reqId, found := RequestId(ctx)
+	if !found {
+		return ctx
+	}
+	if md, exists := metadata.FromOutgoingContext(ctx); exists && len(md.Get(requestIdMetadata)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIdMetadata, reqId)
+}
+
+// RequestIdMetadata returns the request id metadata pair to be sent as grpc header
+func RequestIdMetadata(reqId string) metadata.MD {
+	return metadata.Pairs(requestIdMetadata, reqId)
+}
+
+// SubjectMetadata returns the subject metadata pair to be sent as grpc header,
+// so the http gateway can record who made the request
+func SubjectMetadata(sub string) metadata.MD {
+	return metadata.Pairs(subjectMetadata, sub)
+}
+
+// SubjectFromHeader returns the subject sent by SubjectMetadata
+func SubjectFromHeader(md metadata.MD) (string, bool) {
+	founds := md.Get(subjectMetadata)
+	if len(founds) == 0 {
+		return "", false
+	}
+	return founds[0], true
+}
+
 func XForwardedFor(ctx context.Context) ([]string, bool) {
 	// x-forwarded-for is recording forwarding ips of the requests from very beginning to the handler
 	// the key 'x-forwarded-for' is default key assiged by the grpc-gateway framework
@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	httpStatusMetadata string = "x-http-status"
+)
+
+// SetHttpStatus sets the status of the http response served by the gateway,
+// e.g. 201 Created, 202 Accepted or 204 No Content. the status only applies to
+// successful unary calls, the body of 204 and 304 responses is dropped.
+func SetHttpStatus(ctx context.Context, status int) error {
+	if status < 200 || status > 599 {
+		return fmt.Errorf("runtime: invalid http status %d", status)
+	}
+	return grpc.SetHeader(ctx, metadata.Pairs(httpStatusMetadata, strconv.Itoa(status)))
+}
+
+// SetHttpHeader sets a header of the http response served by the gateway, e.g.
+// Cache-Control, ETag or Content-Disposition. the header must be allowed by
+// the outgoing header matcher of the gateway.
+func SetHttpHeader(ctx context.Context, key, value string) error {
+	key = strings.ToLower(key)
+	if key == httpStatusMetadata || strings.HasPrefix(key, "grpc-") {
+		return fmt.Errorf("runtime: reserved http header %s", key)
+	}
+	return grpc.SetHeader(ctx, metadata.Pairs(key, value))
+}
+
+// Redirect sets the Location header and a redirection status, one of 301,
+// 302, 303, 307 and 308
+func Redirect(ctx context.Context, location string, status int) error {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("runtime: invalid redirect status %d", status)
+	}
+	return grpc.SetHeader(ctx, metadata.Pairs(
+		"location", location,
+		httpStatusMetadata, strconv.Itoa(status),
+	))
+}
+
+// HttpStatusFromHeader returns the status sent by SetHttpStatus or Redirect
+func HttpStatusFromHeader(md metadata.MD) (int, bool) {
+	founds := md.Get(httpStatusMetadata)
+	if len(founds) == 0 {
+		return 0, false
+	}
+	status, err := strconv.Atoi(founds[len(founds)-1])
+	if err != nil || status < 200 || status > 599 {
+		return 0, false
+	}
+	return status, true
+}
+
+type httpErrorBody struct {
+	Code    codes.Code    `json:"code"`
+	Message string        `json:"message"`
+	Details []interface{} `json:"details"`
+}
+
+// WriteHttpError writes err in the error format of the gateway, for handlers
+// served next to it, e.g. login routes
+func WriteHttpError(w http.ResponseWriter, code codes.Code, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(runtime.HTTPStatusFromCode(code))
+	json.NewEncoder(w).Encode(httpErrorBody{Code: code, Message: err.Error(), Details: []interface{}{}})
+}
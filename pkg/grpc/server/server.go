@@ -12,10 +12,12 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	servermiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware"
+	identitymiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/identity"
 	loggermiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/logger"
 	metricmiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/metrics"
 	recoverymiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/recovery"
 	pkglogger "github.com/sdinsure/agent/pkg/logger"
+	"github.com/sdinsure/agent/pkg/redact"
 )
 
 var withReflection = flag.Bool("with_grpc_reflection", false, "turn on grpc reflection")
@@ -30,6 +32,7 @@ type grpcServerConfig struct {
 	unaryInterceptors  []grpc.UnaryServerInterceptor
 	streamInterceptors []grpc.StreamServerInterceptor
 	logger             pkglogger.Logger
+	redactor           *redact.Redactor
 }
 
 type withGrpcPort struct {
@@ -101,6 +104,23 @@ func WithLogger(logger pkglogger.Logger) serverLogger {
 	return serverLogger{logger: logger}
 }
 
+type serverRedactor struct {
+	redactor *redact.Redactor
+}
+
+var (
+	_ GrpcServerConfigurer = serverRedactor{}
+)
+
+func (s serverRedactor) apply(o *grpcServerConfig) {
+	o.redactor = s.redactor
+}
+
+// WithRedactor sets how sensitive request fields are masked in logs
+func WithRedactor(redactor *redact.Redactor) serverRedactor {
+	return serverRedactor{redactor: redactor}
+}
+
 func newConfig(cfgs ...GrpcServerConfigurer) *grpcServerConfig {
 	o := &grpcServerConfig{}
 
@@ -115,7 +135,8 @@ func NewGrpcServer(cfgs ...GrpcServerConfigurer) *GrpcServer {
 
 	beforeMiddlewares := servermiddleware.MultiServerMiddleware(
 		[]servermiddleware.ServerMiddleware{
-			loggermiddleware.NewTagMiddlware(),
+			loggermiddleware.NewTagMiddlware(loggermiddleware.WithRedactor(config.redactor)),
+			identitymiddleware.NewRequestIdentityMiddleware(),
 			metricmiddleware.NewMetricMiddleware(),
 		})
 	afterMiddlewares := servermiddleware.MultiServerMiddleware(
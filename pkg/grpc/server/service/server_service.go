@@ -54,14 +54,19 @@ type ServiceConfig struct {
 	// incoming
 	incomingHeaderMatchFunc IncomingHeaderMatcher
 
-	// outgoing
+	// outgoing, defaults to the allowlist of the http gateway
 	outgoingHeaderMatchFunc OutgoingHeaderMatcher
+	responseHeaders         []string
 
 	log logger.Logger
 
 	marshalers []CustomizeMarshaler
 
 	maxRecvMsgSize int
+
+	corsPolicy *httpgateway.CORSPolicy
+
+	gatewayConfigurers []httpgateway.HttpGatewayServerConfigurer
 }
 
 func newServiceConfig(scs ...ServiceConfigure) *ServiceConfig {
@@ -74,7 +79,6 @@ func newServiceConfig(scs ...ServiceConfigure) *ServiceConfig {
 			GrpcMetadataModifier(grpcmetadata.HttpCookiesToGrpcMetadata),
 		},
 		incomingHeaderMatchFunc: runtime.DefaultHeaderMatcher,
-		outgoingHeaderMatchFunc: deniedAll,
 		maxRecvMsgSize:          64 * 1024 * 1024, /*64M*/
 	}
 	for _, sc := range scs {
@@ -86,10 +90,6 @@ func newServiceConfig(scs ...ServiceConfigure) *ServiceConfig {
 	return c
 }
 
-func deniedAll(headerString string) (string, bool) {
-	return headerString, false
-}
-
 type logVerboseConfigure struct {
 	verbose bool
 }
@@ -240,6 +240,54 @@ func WithMaxRecvMsgSize(size int) maxRecvMsgSize {
 	}
 }
 
+type corsPolicy struct {
+	policy httpgateway.CORSPolicy
+}
+
+func (c corsPolicy) apply(sc *ServiceConfig) {
+	sc.corsPolicy = &c.policy
+}
+
+// WithCORSPolicy sets the cross origin requests allowed by the http gateway
+func WithCORSPolicy(policy httpgateway.CORSPolicy) corsPolicy {
+	return corsPolicy{
+		policy: policy,
+	}
+}
+
+type responseHeaders struct {
+	headers []string
+}
+
+func (r responseHeaders) apply(sc *ServiceConfig) {
+	sc.responseHeaders = append(sc.responseHeaders, r.headers...)
+}
+
+// WithResponseHeaders allows more grpc headers to be forwarded as http response
+// headers, in addition to httpgateway.DefaultResponseHeaders
+func WithResponseHeaders(headers ...string) responseHeaders {
+	return responseHeaders{
+		headers: headers,
+	}
+}
+
+type httpGatewayOption struct {
+	configurers []httpgateway.HttpGatewayServerConfigurer
+}
+
+func (h httpGatewayOption) apply(sc *ServiceConfig) {
+	sc.gatewayConfigurers = append(sc.gatewayConfigurers, h.configurers...)
+}
+
+// WithHTTPGatewayOption passes configurers to the http gateway, e.g.
+// httpgateway.WithWebSocketBridge, httpgateway.WithServerSentEvents or
+// httpgateway.WithSession. they apply after the options of the service.
+func WithHTTPGatewayOption(configurers ...httpgateway.HttpGatewayServerConfigurer) httpGatewayOption {
+	return httpGatewayOption{
+		configurers: configurers,
+	}
+}
+
 func NewServerService(
 	grpcPort int,
 	httpPort int,
@@ -270,16 +318,28 @@ func NewServerService(
 	}
 	serveMuxOptions = append(serveMuxOptions,
 		runtime.WithIncomingHeaderMatcher(runtime.HeaderMatcherFunc(config.incomingHeaderMatchFunc)),
-		runtime.WithOutgoingHeaderMatcher(runtime.HeaderMatcherFunc(config.outgoingHeaderMatchFunc)),
 	)
+	if config.outgoingHeaderMatchFunc != nil {
+		serveMuxOptions = append(serveMuxOptions, runtime.WithOutgoingHeaderMatcher(runtime.HeaderMatcherFunc(config.outgoingHeaderMatchFunc)))
+	}
 
+	gatewayConfigurers := []httpgateway.HttpGatewayServerConfigurer{
+		httpgateway.WithMaxCallRecvMsgSize(config.maxRecvMsgSize),
+		httpgateway.WithTransportCredentials(config.clientTransportCredentials),
+		httpgateway.WithServeMuxOption(serveMuxOptions...),
+	}
+	if len(config.responseHeaders) > 0 {
+		gatewayConfigurers = append(gatewayConfigurers, httpgateway.WithResponseHeaders(config.responseHeaders...))
+	}
+	if config.corsPolicy != nil {
+		gatewayConfigurers = append(gatewayConfigurers, httpgateway.WithCORSPolicy(*config.corsPolicy))
+	}
+	gatewayConfigurers = append(gatewayConfigurers, config.gatewayConfigurers...)
 	httpGateway, err := httpgateway.NewHTTPGatewayServer(
 		svr,
 		config.log,
 		httpPort,
-		httpgateway.WithMaxCallRecvMsgSize(config.maxRecvMsgSize),
-		httpgateway.WithTransportCredentials(config.clientTransportCredentials),
-		httpgateway.WithServeMuxOption(serveMuxOptions...),
+		gatewayConfigurers...,
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,352 @@
+// Package session keeps the bearer token of browsers in an encrypted cookie,
+// and protects requests authenticated by cookies from CSRF with a double
+// submit token: state changing requests must echo the csrf cookie in the
+// X-CSRF-Token header, which other sites cannot read.
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	authnmiddleware "github.com/sdinsure/agent/pkg/grpc/server/middleware/authn"
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+var (
+	ErrNoSession = errors.New("session: no session")
+	ErrCSRF      = errors.New("session: csrf token mismatch")
+)
+
+// legacyAuthorizationCookie is promoted to authorization by
+// metadata.HttpCookiesToGrpcMetadata
+const legacyAuthorizationCookie = "authorization"
+
+type Config struct {
+	// Keys encrypt and authenticate cookies with AES-256-GCM, 32 bytes each.
+	// the first key encrypts, all keys decrypt, so keys can be rotated.
+	Keys [][]byte
+
+	// CookieName defaults to "session"
+	CookieName string
+	// CSRFCookieName defaults to "csrf_token", it is readable by scripts
+	CSRFCookieName string
+	// CSRFHeader defaults to "X-CSRF-Token"
+	CSRFHeader string
+
+	// Path defaults to "/"
+	Path   string
+	Domain string
+	// Insecure drops the Secure attribute, e.g. for http on localhost
+	Insecure bool
+	// SameSite defaults to http.SameSiteLaxMode
+	SameSite http.SameSite
+	// MaxAge caps the lifetime of sessions, defaults to 12h. sessions end
+	// earlier when their token expires.
+	MaxAge time.Duration
+}
+
+type Session struct {
+	Token     string            `json:"tok"`
+	CSRF      string            `json:"csrf"`
+	ExpiresAt time.Time         `json:"exp"`
+	Values    map[string]string `json:"vals,omitempty"`
+}
+
+type Manager struct {
+	cfg   Config
+	aeads []cipher.AEAD
+}
+
+func NewManager(cfg Config) (*Manager, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("session: at least one key is required")
+	}
+	if len(cfg.CookieName) == 0 {
+		cfg.CookieName = "session"
+	}
+	if len(cfg.CSRFCookieName) == 0 {
+		cfg.CSRFCookieName = "csrf_token"
+	}
+	if len(cfg.CSRFHeader) == 0 {
+		cfg.CSRFHeader = "X-CSRF-Token"
+	}
+	if len(cfg.Path) == 0 {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 12 * time.Hour
+	}
+
+	m := &Manager{cfg: cfg}
+	for i, key := range cfg.Keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("session: key %d is %d bytes, 32 bytes are required", i, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		m.aeads = append(m.aeads, aead)
+	}
+	return m, nil
+}
+
+// Save sets the session and csrf cookies, CSRF and ExpiresAt are filled if empty
+func (m *Manager) Save(w http.ResponseWriter, s *Session) error {
+	if len(s.CSRF) == 0 {
+		csrf, err := randomToken()
+		if err != nil {
+			return err
+		}
+		s.CSRF = csrf
+	}
+	if maxExpiresAt := time.Now().Add(m.cfg.MaxAge); s.ExpiresAt.IsZero() || s.ExpiresAt.After(maxExpiresAt) {
+		s.ExpiresAt = maxExpiresAt
+	}
+	value, err := m.encrypt(s)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, m.cookie(m.cfg.CookieName, value, s.ExpiresAt, true))
+	http.SetCookie(w, m.cookie(m.cfg.CSRFCookieName, s.CSRF, s.ExpiresAt, false))
+	return nil
+}
+
+// Load returns the session of r, or ErrNoSession if it is missing, invalid or
+// expired
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.cfg.CookieName)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	s, err := m.decrypt(cookie.Value)
+	if err != nil || time.Now().After(s.ExpiresAt) {
+		return nil, ErrNoSession
+	}
+	return s, nil
+}
+
+// Clear expires the session and csrf cookies
+func (m *Manager) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, m.cookie(m.cfg.CookieName, "", time.Unix(0, 0), true))
+	http.SetCookie(w, m.cookie(m.cfg.CSRFCookieName, "", time.Unix(0, 0), false))
+}
+
+// Middleware authenticates requests with a session by its token, as if it was
+// sent as Authorization header. state changing requests authenticated by
+// cookies, including the authorization cookie, must pass the csrf check.
+// requests with an Authorization header are not affected.
+func (m *Manager) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) > 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		s, err := m.Load(r)
+		switch {
+		case err == nil:
+			if err := m.CheckCSRF(r, s); err != nil {
+				runtime.WriteHttpError(w, codes.PermissionDenied, err)
+				return
+			}
+			r.Header.Set("Authorization", "Bearer "+s.Token)
+		case hasCookie(r, legacyAuthorizationCookie):
+			if err := m.CheckCSRF(r, nil); err != nil {
+				runtime.WriteHttpError(w, codes.PermissionDenied, err)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// CheckCSRF passes safe methods, other requests must send the csrf cookie in
+// the csrf header, and match the csrf token of s if s is not nil
+func (m *Manager) CheckCSRF(r *http.Request, s *Session) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+	header := r.Header.Get(m.cfg.CSRFHeader)
+	cookie, err := r.Cookie(m.cfg.CSRFCookieName)
+	if len(header) == 0 || err != nil || !equal(header, cookie.Value) {
+		return ErrCSRF
+	}
+	if s != nil && !equal(header, s.CSRF) {
+		return ErrCSRF
+	}
+	return nil
+}
+
+type loginRequest struct {
+	Token string `json:"token"`
+}
+
+type loginResponse struct {
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LoginHandler starts a session for the bearer token sent as Authorization
+// header or as {"token": ...} body of content type application/json. both
+// require a cors preflight from other sites, so forms cannot log browsers into
+// the session of an attacker. the token is verified by parser, and the session
+// ends when the token expires. the csrf token is returned as
+// {"csrf_token": ..., "expires_at": ...}.
+func (m *Manager) LoginHandler(parser authnmiddleware.ClaimParser) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			runtime.WriteHttpError(w, codes.Unimplemented, errors.New("session: login requires POST"))
+			return
+		}
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				runtime.WriteHttpError(w, codes.InvalidArgument, errors.New("session: login requires content type application/json"))
+				return
+			}
+			req := loginRequest{}
+			if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&req); err != nil || len(req.Token) == 0 {
+				runtime.WriteHttpError(w, codes.InvalidArgument, errors.New("session: token is required"))
+				return
+			}
+			token = req.Token
+		}
+		claims, err := parser.ParseClaim(r.Context(), token)
+		if err != nil {
+			runtime.WriteHttpError(w, codes.Unauthenticated, err)
+			return
+		}
+		s := &Session{Token: token}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			s.ExpiresAt = exp.Time
+		}
+		if err := m.Save(w, s); err != nil {
+			runtime.WriteHttpError(w, codes.Internal, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, loginResponse{CSRFToken: s.CSRF, ExpiresAt: s.ExpiresAt})
+	})
+}
+
+// LogoutHandler ends the session, it requires the csrf check
+func (m *Manager) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			runtime.WriteHttpError(w, codes.Unimplemented, errors.New("session: logout requires POST"))
+			return
+		}
+		s, err := m.Load(r)
+		if err == nil {
+			if err := m.CheckCSRF(r, s); err != nil {
+				runtime.WriteHttpError(w, codes.PermissionDenied, err)
+				return
+			}
+		}
+		m.Clear(w)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (m *Manager) cookie(name, value string, expiresAt time.Time, httpOnly bool) *http.Cookie {
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     m.cfg.Path,
+		Domain:   m.cfg.Domain,
+		Expires:  expiresAt,
+		MaxAge:   maxAge,
+		Secure:   !m.cfg.Insecure,
+		HttpOnly: httpOnly,
+		SameSite: m.cfg.SameSite,
+	}
+}
+
+func (m *Manager) encrypt(s *Session) (string, error) {
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	aead := m.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// the cookie name is authenticated, so values cannot be moved across cookies
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(m.cfg.CookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (m *Manager) decrypt(value string) (*Session, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, aead := range m.aeads {
+		if len(sealed) < aead.NonceSize() {
+			return nil, ErrNoSession
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(m.cfg.CookieName))
+		if err != nil {
+			continue
+		}
+		s := &Session{}
+		if err := json.Unmarshal(plaintext, s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, ErrNoSession
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func hasCookie(r *http.Request, name string) bool {
+	for _, cookie := range r.Cookies() {
+		if strings.ToLower(cookie.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,154 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClaimParser struct {
+	expiresAt time.Time
+}
+
+func (f fakeClaimParser) ParseClaim(ctx context.Context, token string) (jwt.Claims, error) {
+	if token != "valid" {
+		return nil, errors.New("invalid token")
+	}
+	return jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(f.expiresAt)}, nil
+}
+
+func newTestManager(t *testing.T, keys ...[]byte) *Manager {
+	if len(keys) == 0 {
+		keys = [][]byte{bytes.Repeat([]byte{1}, 32)}
+	}
+	m, err := NewManager(Config{Keys: keys, Domain: "example.com", SameSite: http.SameSiteStrictMode})
+	assert.NoError(t, err)
+	return m
+}
+
+func login(t *testing.T, m *Manager) ([]*http.Cookie, string) {
+	expiresAt := time.Now().Add(time.Hour)
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"token":"valid"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	m.LoginHandler(fakeClaimParser{expiresAt: expiresAt}).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	resp := loginResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.WithinDuration(t, expiresAt, resp.ExpiresAt, time.Second)
+	return w.Result().Cookies(), resp.CSRFToken
+}
+
+func TestLogin(t *testing.T) {
+	m := newTestManager(t)
+	cookies, csrf := login(t, m)
+
+	assert.Len(t, cookies, 2)
+	sessionCookie, csrfCookie := cookies[0], cookies[1]
+	assert.Equal(t, "session", sessionCookie.Name)
+	assert.True(t, sessionCookie.HttpOnly)
+	assert.True(t, sessionCookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, sessionCookie.SameSite)
+	assert.Equal(t, "example.com", sessionCookie.Domain)
+	assert.NotContains(t, sessionCookie.Value, "valid")
+	assert.Equal(t, "csrf_token", csrfCookie.Name)
+	assert.False(t, csrfCookie.HttpOnly)
+	assert.Equal(t, csrf, csrfCookie.Value)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"token":"invalid"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	w := httptest.NewRecorder()
+	m.LoginHandler(fakeClaimParser{}).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// forms of other sites can post text/plain without a preflight
+	req = httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"token":"valid"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	m.LoginHandler(fakeClaimParser{}).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestMiddleware(t *testing.T) {
+	m := newTestManager(t)
+	cookies, csrf := login(t, m)
+
+	var authorization string
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	serve := func(method string, csrfHeader string, cookies ...*http.Cookie) int {
+		authorization = ""
+		req := httptest.NewRequest(method, "/v1/projects", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		if len(csrfHeader) > 0 {
+			req.Header.Set("X-CSRF-Token", csrfHeader)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "", cookies...))
+	assert.Equal(t, "Bearer valid", authorization)
+
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "", cookies...))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "forged", cookies...))
+	assert.Empty(t, authorization)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, csrf, cookies...))
+	assert.Equal(t, "Bearer valid", authorization)
+
+	// the authorization cookie requires the csrf check as well
+	legacy := &http.Cookie{Name: "Authorization", Value: "Bearer legacy"}
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "", legacy))
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, "token", legacy, &http.Cookie{Name: "csrf_token", Value: "token"}))
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	cookies, _ := login(t, newTestManager(t, oldKey))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	s, err := newTestManager(t, newKey, oldKey).Load(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "valid", s.Token)
+
+	_, err = newTestManager(t, newKey).Load(req)
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func TestLogout(t *testing.T) {
+	m := newTestManager(t)
+	cookies, csrf := login(t, m)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	m.LogoutHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req.Header.Set("X-CSRF-Token", csrf)
+	w = httptest.NewRecorder()
+	m.LogoutHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	for _, cookie := range w.Result().Cookies() {
+		assert.Empty(t, cookie.Value)
+		assert.Less(t, cookie.MaxAge, 0)
+	}
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	sdinsureerrors "github.com/sdinsure/agent/pkg/errors"
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+// authenticator sets the credentials of an outgoing request, the request is
+// a clone which can be modified
+type authenticator interface {
+	authenticate(req *http.Request) error
+}
+
+// WithBearerToken authenticates requests with a static bearer token
+func WithBearerToken(token string) Option {
+	return withAuthenticator{staticBearer{token: token}}
+}
+
+// WithBearerForwarding forwards the token of the incoming call in context
+// (see runtime.KeyInfo), requests without token fail
+func WithBearerForwarding() Option {
+	return withAuthenticator{contextBearer{}}
+}
+
+// ClientCredentialsConfig fetches tokens with the OAuth2 client credentials
+// grant. tokens are cached and refreshed shortly before they expire.
+type ClientCredentialsConfig struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams map[string][]string
+	// HttpClient requests TokenURL, defaults to http.DefaultClient
+	HttpClient *http.Client
+}
+
+func WithClientCredentials(cc ClientCredentialsConfig) Option {
+	return withAuthenticator{newClientCredentials(cc)}
+}
+
+// HMACConfig signs requests with HMAC-SHA256, see SignRequest
+type HMACConfig struct {
+	KeyId  string
+	Secret []byte
+}
+
+func WithHMACSigning(h HMACConfig) Option {
+	return withAuthenticator{hmacSigner{cfg: h}}
+}
+
+type withAuthenticator struct {
+	authenticator authenticator
+}
+
+func (w withAuthenticator) apply(cfg config) config {
+	cfg.Authenticator = w.authenticator
+	return cfg
+}
+
+type authTransport struct {
+	t             http.RoundTripper
+	authenticator authenticator
+}
+
+func (a authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper should not modify the original request
+	req = req.Clone(req.Context())
+	if err := a.authenticator.authenticate(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return a.t.RoundTrip(req)
+}
+
+type staticBearer struct {
+	token string
+}
+
+func (s staticBearer) authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	return nil
+}
+
+type contextBearer struct{}
+
+func (contextBearer) authenticate(req *http.Request) error {
+	token, found := runtime.KeyInfo(req.Context())
+	if !found || len(token) == 0 {
+		return sdinsureerrors.NewInvalidAuth(errors.New("auth: keyinfo not found"))
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+type clientCredentials struct {
+	tokenSource oauth2.TokenSource
+}
+
+func newClientCredentials(cc ClientCredentialsConfig) clientCredentials {
+	ccConfig := &clientcredentials.Config{
+		ClientID:       cc.ClientID,
+		ClientSecret:   cc.ClientSecret,
+		TokenURL:       cc.TokenURL,
+		Scopes:         cc.Scopes,
+		EndpointParams: cc.EndpointParams,
+	}
+	ctx := context.Background()
+	if cc.HttpClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, cc.HttpClient)
+	}
+	// the token source caches the token until it expires
+	return clientCredentials{tokenSource: ccConfig.TokenSource(ctx)}
+}
+
+func (c clientCredentials) authenticate(req *http.Request) error {
+	token, err := c.tokenSource.Token()
+	if err != nil {
+		return sdinsureerrors.NewInvalidAuth(fmt.Errorf("auth: fetch token failed: %w", err))
+	}
+	token.SetAuthHeader(req)
+	return nil
+}
+
+const (
+	HMACTimestampHeader = "X-Signature-Timestamp"
+	HMACSignatureHeader = "X-Signature"
+)
+
+type hmacSigner struct {
+	cfg HMACConfig
+}
+
+func (h hmacSigner) authenticate(req *http.Request) error {
+	return SignRequest(req, h.cfg, time.Now())
+}
+
+// SignRequest sets X-Signature-Timestamp and
+// X-Signature: keyId=<id>,signature=<hex hmac-sha256>
+// the signed string is method, path with query, timestamp and the hex sha256
+// of the body joined by newlines
+func SignRequest(req *http.Request, h HMACConfig, now time.Time) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(HMACTimestampHeader, timestamp)
+	req.Header.Set(HMACSignatureHeader, fmt.Sprintf("keyId=%s,signature=%s", h.KeyId, hmacSignature(req, h.Secret, timestamp, body)))
+	return nil
+}
+
+// VerifyRequest verifies the signature set by SignRequest, the timestamp must
+// be within maxSkew of now
+func VerifyRequest(req *http.Request, secretOf func(keyId string) ([]byte, bool), maxSkew time.Duration, now time.Time) error {
+	timestamp := req.Header.Get(HMACTimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return sdinsureerrors.NewInvalidAuth(errors.New("auth: invalid signature timestamp"))
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return sdinsureerrors.NewInvalidAuth(errors.New("auth: signature expired"))
+	}
+	var keyId, signature string
+	for _, kv := range strings.Split(req.Header.Get(HMACSignatureHeader), ",") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "keyId":
+			keyId = v
+		case "signature":
+			signature = v
+		}
+	}
+	secret, found := secretOf(keyId)
+	if !found {
+		return sdinsureerrors.NewInvalidAuth(fmt.Errorf("auth: unknown key id %q", keyId))
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	expected := hmacSignature(req, secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return sdinsureerrors.NewInvalidAuth(errors.New("auth: invalid signature"))
+	}
+	return nil
+}
+
+func hmacSignature(req *http.Request, secret []byte, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		timestamp,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readBody reads the body and rewinds it for the next reader
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
+)
+
+func newAuthServer(t *testing.T) (*httptest.Server, *string) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &authorization
+}
+
+func TestBearerToken(t *testing.T) {
+	srv, authorization := newAuthServer(t)
+
+	resp, err := NewHttpClient(WithBearerToken("static")).Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer static", *authorization)
+
+	client := NewHttpClient(WithBearerForwarding())
+	req, _ := http.NewRequestWithContext(runtime.WithKeyInfo(context.Background(), "forwarded"), http.MethodGet, srv.URL, nil)
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "Bearer forwarded", *authorization)
+
+	_, err = client.Get(srv.URL)
+	assert.Error(t, err)
+}
+
+func TestClientCredentials(t *testing.T) {
+	var tokenRequests int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokenRequests, 1)
+		clientId, clientSecret, _ := r.BasicAuth()
+		assert.Equal(t, "id", clientId)
+		assert.Equal(t, "secret", clientSecret)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+	srv, authorization := newAuthServer(t)
+
+	client := NewHttpClient(WithClientCredentials(ClientCredentialsConfig{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+	}))
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "Bearer token-1", *authorization)
+	}
+	// token is cached
+	assert.EqualValues(t, 1, atomic.LoadInt32(&tokenRequests))
+}
+
+func TestHMACSigning(t *testing.T) {
+	secrets := map[string][]byte{"key": []byte("secret")}
+	secretOf := func(keyId string) ([]byte, bool) {
+		secret, found := secrets[keyId]
+		return secret, found
+	}
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyErr = VerifyRequest(r, secretOf, time.Minute, time.Now())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(WithHMACSigning(HMACConfig{KeyId: "key", Secret: []byte("secret")}))
+	resp, err := client.Post(srv.URL+"/v1/items?x=1", "application/json", strings.NewReader(`{"a":1}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NoError(t, verifyErr)
+
+	client = NewHttpClient(WithHMACSigning(HMACConfig{KeyId: "key", Secret: []byte("wrong")}))
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader(`{"a":1}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Error(t, verifyErr)
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("http: circuit breaker is open")
+
+// BreakerConfig opens the circuit of a host after FailureThreshold consecutive
+// failures (transport errors and 5xx responses). requests fail fast with
+// ErrCircuitOpen until OpenDuration has passed, then a single probe request
+// closes the circuit on success or opens it again.
+type BreakerConfig struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+}
+
+func WithCircuitBreaker(breaker BreakerConfig) Option {
+	return withCircuitBreaker{breaker: breaker}
+}
+
+type withCircuitBreaker struct {
+	breaker BreakerConfig
+}
+
+func (w withCircuitBreaker) apply(cfg config) config {
+	cfg.Breaker = &w.breaker
+	return cfg
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (b breakerState) String() string {
+	switch b {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+type hostBreaker struct {
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+type breakerTransport struct {
+	t   http.RoundTripper
+	cfg BreakerConfig
+
+	mu    *sync.Mutex
+	hosts map[string]*hostBreaker
+}
+
+func newBreakerTransport(t http.RoundTripper, cfg BreakerConfig) breakerTransport {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultBreakerConfig.FailureThreshold
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = DefaultBreakerConfig.OpenDuration
+	}
+	return breakerTransport{
+		t:     t,
+		cfg:   cfg,
+		mu:    &sync.Mutex{},
+		hosts: make(map[string]*hostBreaker),
+	}
+}
+
+func (b breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := b.allow(req, host); err != nil {
+		return nil, err
+	}
+	resp, err := b.t.RoundTrip(req)
+	b.record(req, host, err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
+
+func (b breakerTransport) allow(req *http.Request, host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hb, found := b.hosts[host]
+	if !found {
+		hb = &hostBreaker{}
+		b.hosts[host] = hb
+	}
+	switch hb.state {
+	case breakerOpen:
+		if time.Since(hb.openedAt) < b.cfg.OpenDuration {
+			return ErrCircuitOpen
+		}
+		b.transitionLocked(req, host, hb, breakerHalfOpen)
+		hb.probing = true
+		return nil
+	case breakerHalfOpen:
+		// only one probe at a time
+		if hb.probing {
+			return ErrCircuitOpen
+		}
+		hb.probing = true
+	}
+	return nil
+}
+
+func (b breakerTransport) record(req *http.Request, host string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hb := b.hosts[host]
+	hb.probing = false
+	if success {
+		hb.failures = 0
+		if hb.state != breakerClosed {
+			b.transitionLocked(req, host, hb, breakerClosed)
+		}
+		return
+	}
+	hb.failures++
+	if hb.state == breakerHalfOpen || hb.failures >= b.cfg.FailureThreshold {
+		hb.openedAt = time.Now()
+		if hb.state != breakerOpen {
+			b.transitionLocked(req, host, hb, breakerOpen)
+		}
+	}
+}
+
+func (b breakerTransport) transitionLocked(req *http.Request, host string, hb *hostBreaker, state breakerState) {
+	if hb.state == breakerOpen {
+		metricHttpClientBreakerOpen.Add(req.Context(), -1, host)
+	}
+	if state == breakerOpen {
+		metricHttpClientBreakerOpen.Add(req.Context(), 1, host)
+	}
+	hb.state = state
+	metricHttpClientBreakerTransitionsTotal.Inc(req.Context(), host, state.String())
+}
@@ -0,0 +1,385 @@
+// Package cassette records http interactions to a file and replays them, so
+// client tests run without the server, e.g.
+//
+//	rec, err := cassette.New("testdata/hello.json")
+//	defer rec.Stop()
+//	client := &http.Client{Transport: sdinsurehttp.NewHttpTransport(rec)}
+//
+// run the tests once with HTTP_CASSETTE_MODE=record against a live server to
+// (re-)record the cassette.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sdinsure/agent/pkg/redact"
+)
+
+// ModeEnv overrides the mode of all recorders, e.g. HTTP_CASSETTE_MODE=record
+const ModeEnv = "HTTP_CASSETTE_MODE"
+
+type Mode string
+
+const (
+	// ModeReplay replays recorded interactions and fails requests not recorded
+	ModeReplay Mode = "replay"
+	// ModeRecord sends all requests and records them, replacing the cassette
+	ModeRecord Mode = "record"
+	// ModeReplayOrRecord replays recorded interactions and records the others
+	ModeReplayOrRecord Mode = "replay_or_record"
+)
+
+var ErrInteractionNotFound = errors.New("cassette: interaction not found")
+
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is stored as text, or base64 encoded if it is not valid utf-8
+type Body struct {
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+func newBody(b []byte) Body {
+	if utf8.Valid(b) {
+		return Body{Text: string(b)}
+	}
+	return Body{Text: base64.StdEncoding.EncodeToString(b), Encoding: "base64"}
+}
+
+func (b Body) Bytes() []byte {
+	if b.Encoding == "base64" {
+		decoded, _ := base64.StdEncoding.DecodeString(b.Text)
+		return decoded
+	}
+	return []byte(b.Text)
+}
+
+type RecorderOptioner interface {
+	apply(*RecorderOptions)
+}
+
+type RecorderOptions struct {
+	mode         Mode
+	transport    http.RoundTripper
+	matchHeaders []string
+	ignoreBody   bool
+	redactors    []func(*Interaction)
+}
+
+type mode struct {
+	mode Mode
+}
+
+func (m mode) apply(o *RecorderOptions) {
+	o.mode = m.mode
+}
+
+// WithMode sets the mode unless overridden by ModeEnv, defaults to ModeReplay
+func WithMode(m Mode) mode {
+	return mode{mode: m}
+}
+
+type transport struct {
+	transport http.RoundTripper
+}
+
+func (t transport) apply(o *RecorderOptions) {
+	o.transport = t.transport
+}
+
+// WithTransport sends recorded requests, defaults to http.DefaultTransport
+func WithTransport(rt http.RoundTripper) transport {
+	return transport{transport: rt}
+}
+
+type matchHeaders struct {
+	names []string
+}
+
+func (m matchHeaders) apply(o *RecorderOptions) {
+	o.matchHeaders = append(o.matchHeaders, m.names...)
+}
+
+// WithMatchHeaders matches requests on the headers in addition to method, url
+// and body. headers masked by redaction do not match.
+func WithMatchHeaders(names ...string) matchHeaders {
+	return matchHeaders{names: names}
+}
+
+type ignoreBody struct{}
+
+func (ignoreBody) apply(o *RecorderOptions) {
+	o.ignoreBody = true
+}
+
+// WithIgnoreBody matches requests regardless of their body
+func WithIgnoreBody() ignoreBody {
+	return ignoreBody{}
+}
+
+type redactor struct {
+	redact func(*Interaction)
+}
+
+func (r redactor) apply(o *RecorderOptions) {
+	o.redactors = append(o.redactors, r.redact)
+}
+
+// WithRedactor modifies interactions before they are saved, e.g. to mask
+// tokens in bodies. Authorization, Cookie and Set-Cookie headers are always
+// masked.
+func WithRedactor(redact func(*Interaction)) redactor {
+	return redactor{redact: redact}
+}
+
+func newOptions(opts ...RecorderOptioner) *RecorderOptions {
+	o := &RecorderOptions{
+		mode:      ModeReplay,
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	if m := Mode(os.Getenv(ModeEnv)); len(m) > 0 {
+		o.mode = m
+	}
+	return o
+}
+
+// Recorder is a http.RoundTripper recording to and replaying from a cassette file
+type Recorder struct {
+	path string
+	opts *RecorderOptions
+
+	mu       sync.Mutex
+	cassette *Cassette
+	replayed map[*Interaction]bool
+	dirty    bool
+}
+
+var _ http.RoundTripper = &Recorder{}
+
+// New loads the cassette at path, which may not exist unless replaying
+func New(path string, opts ...RecorderOptioner) (*Recorder, error) {
+	o := newOptions(opts...)
+	switch o.mode {
+	case ModeReplay, ModeRecord, ModeReplayOrRecord:
+	default:
+		return nil, fmt.Errorf("cassette: unknown mode %q", o.mode)
+	}
+
+	r := &Recorder{
+		path:     path,
+		opts:     o,
+		cassette: &Cassette{},
+		replayed: make(map[*Interaction]bool),
+	}
+	if o.mode == ModeRecord {
+		return r, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && o.mode == ModeReplayOrRecord {
+			return r, nil
+		}
+		return nil, fmt.Errorf("cassette: load %s failed: %w", path, err)
+	}
+	if err := json.Unmarshal(b, r.cassette); err != nil {
+		return nil, fmt.Errorf("cassette: parse %s failed: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper should not modify the original request
+	req = req.Clone(req.Context())
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.opts.mode != ModeRecord {
+		if interaction, found := r.find(req, body); found {
+			return newResponse(req, interaction.Response), nil
+		}
+		if r.opts.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+		}
+	}
+
+	resp, err := r.opts.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   newBody(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       newBody(respBody),
+		},
+	}
+	r.redact(interaction)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.replayed[interaction] = true
+	r.dirty = true
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Stop saves the recorded interactions, it is a noop when replaying
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.path, b, 0o644); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// find returns the first matching interaction not replayed yet, or the last
+// matching one, so repeated requests replay recorded sequences in order
+func (r *Recorder) find(req *http.Request, body []byte) (*Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *Interaction
+	for _, interaction := range r.cassette.Interactions {
+		if !r.match(req, body, interaction.Request) {
+			continue
+		}
+		if !r.replayed[interaction] {
+			r.replayed[interaction] = true
+			return interaction, true
+		}
+		last = interaction
+	}
+	return last, last != nil
+}
+
+func (r *Recorder) match(req *http.Request, body []byte, recorded Request) bool {
+	if req.Method != recorded.Method || req.URL.String() != recorded.URL {
+		return false
+	}
+	for _, name := range r.opts.matchHeaders {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(recorded.Header.Values(name), ",") {
+			return false
+		}
+	}
+	if r.opts.ignoreBody {
+		return true
+	}
+	return bytes.Equal(normalizeBody(body), normalizeBody(recorded.Body.Bytes()))
+}
+
+func (r *Recorder) redact(interaction *Interaction) {
+	redactor := redact.NewRedactor()
+	interaction.Request.Header = redactor.Header(interaction.Request.Header)
+	interaction.Response.Header = redactor.Header(interaction.Response.Header)
+	for _, redact := range r.opts.redactors {
+		redact(interaction)
+	}
+}
+
+// normalizeBody compacts json bodies and sorts their keys, so the encoding of
+// a client does not break matching
+func normalizeBody(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+// readBody reads the request body and rewinds it for the transport
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func newResponse(req *http.Request, recorded Response) *http.Response {
+	body := recorded.Body.Bytes()
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
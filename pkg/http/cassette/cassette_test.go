@@ -0,0 +1,90 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sdinsure/agent/pkg/redact"
+)
+
+func TestRecordReplay(t *testing.T) {
+	t.Setenv(ModeEnv, "")
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"echo":` + string(b) + `,"secret":"s"}`))
+	}))
+
+	rec, err := New(path,
+		WithMode(ModeRecord),
+		WithMatchHeaders("X-Tenant"),
+		WithRedactor(func(i *Interaction) {
+			i.Response.Body.Text = strings.ReplaceAll(i.Response.Body.Text, `"s"`, `"`+redact.Mask+`"`)
+		}),
+	)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: rec}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/items", strings.NewReader(`{"b":2,"a":1}`))
+	req.Header.Set("X-Tenant", "t1")
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, string(body), `"secret":"s"`)
+	assert.NoError(t, rec.Stop())
+	srv.Close()
+
+	saved, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(saved), "Bearer token")
+	assert.NotContains(t, string(saved), "session=abc")
+	assert.NotContains(t, string(saved), `\"s\"`)
+
+	// replay offline, json bodies match regardless of key order
+	rec, err = New(path, WithMatchHeaders("X-Tenant"))
+	assert.NoError(t, err)
+	client = &http.Client{Transport: rec}
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/items", strings.NewReader(`{"a":1,"b":2}`))
+	req.Header.Set("X-Tenant", "t1")
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, string(body), redact.Mask)
+	assert.Equal(t, 1, calls)
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/items", strings.NewReader(`{"a":1,"b":2}`))
+	req.Header.Set("X-Tenant", "t2")
+	_, err = client.Do(req)
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	t.Setenv(ModeEnv, "")
+	path := filepath.Join(t.TempDir(), "missing.json")
+
+	_, err := New(path)
+	assert.Error(t, err)
+
+	rec, err := New(path, WithMode(ModeReplayOrRecord))
+	assert.NoError(t, err)
+	assert.NoError(t, rec.Stop())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	logger "github.com/sdinsure/agent/pkg/logger"
+	"github.com/sdinsure/agent/pkg/redact"
+)
+
+// DebugConfig configures the traffic logged at debug level by WithDebugConfig
+type DebugConfig struct {
+	Log logger.Logger
+
+	// RequestBody and ResponseBody include bodies, capped by the redactor's
+	// MaxBodyBytes. bodies of streaming responses (SSE, NDJSON) are never
+	// captured, so streams are not buffered.
+	RequestBody  bool
+	ResponseBody bool
+	// Redactor masks headers and json keys, defaults to redact.NewRedactor()
+	Redactor *redact.Redactor
+	// SampleRatio in (0, 1] logs the ratio of requests, failed requests are
+	// always logged. 0 logs all requests.
+	SampleRatio float64
+}
+
+func WithDebug(debug bool, log logger.Logger) Option {
+	return withDebug{debug: debug, cfg: DebugConfig{Log: log}}
+}
+
+func WithDebugConfig(debug DebugConfig) Option {
+	return withDebug{debug: true, cfg: debug}
+}
+
+type withDebug struct {
+	debug bool
+	cfg   DebugConfig
+}
+
+func (w withDebug) apply(cfg config) config {
+	cfg.Debug = w.debug
+	cfg.DebugConfig = w.cfg
+	return cfg
+}
+
+type debugTransport struct {
+	t   http.RoundTripper
+	cfg DebugConfig
+}
+
+func newDebugTransport(t http.RoundTripper, cfg DebugConfig) debugTransport {
+	if cfg.Redactor == nil {
+		cfg.Redactor = redact.NewRedactor()
+	}
+	return debugTransport{t: t, cfg: cfg}
+}
+
+func (d debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sampled := d.sampled()
+	kv := []interface{}{
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"request_headers", d.cfg.Redactor.Header(req.Header),
+	}
+	if d.cfg.RequestBody && sampled {
+		kv = append(kv, "request_body", d.requestBody(req))
+	}
+
+	start := time.Now()
+	resp, err := d.t.RoundTrip(req)
+	if err != nil {
+		kv = append(kv, "latency", time.Since(start), "error", err.Error())
+		d.log(req.Context(), kv)
+		return nil, err
+	}
+	if !sampled && resp.StatusCode < 400 {
+		return resp, nil
+	}
+	kv = append(kv,
+		"status", resp.StatusCode,
+		"response_headers", d.cfg.Redactor.Header(resp.Header),
+		"latency", time.Since(start),
+	)
+	if !d.cfg.ResponseBody || isStreaming(resp) || resp.Body == nil || resp.Body == http.NoBody {
+		d.log(req.Context(), kv)
+		return resp, nil
+	}
+	// the body is logged once the caller is done with it, only the capped
+	// prefix is kept
+	resp.Body = &capturingBody{
+		ReadCloser: resp.Body,
+		max:        d.cfg.Redactor.MaxBodyBytes(),
+		done: func(body []byte) {
+			d.log(req.Context(), append(kv, "response_body", d.cfg.Redactor.Body(body)))
+		},
+	}
+	return resp, nil
+}
+
+func (d debugTransport) sampled() bool {
+	ratio := d.cfg.SampleRatio
+	return ratio <= 0 || ratio >= 1 || rand.Float64() < ratio
+}
+
+// requestBody reads the body of requests which can be replayed, the body of
+// other requests is not logged as reading it would consume it
+func (d debugTransport) requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	if req.GetBody == nil {
+		return "(unavailable)"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "(unavailable)"
+	}
+	defer body.Close()
+	// keep one more byte than the cap, so redaction knows the body was truncated
+	b, _ := io.ReadAll(io.LimitReader(body, int64(d.cfg.Redactor.MaxBodyBytes())+1))
+	return d.cfg.Redactor.Body(b)
+}
+
+func (d debugTransport) log(ctx context.Context, kv []interface{}) {
+	if d.cfg.Log == nil {
+		return
+	}
+	if structured, ok := d.cfg.Log.(logger.StructuredLogger); ok {
+		structured.DebugKV(ctx, "http client request", kv...)
+		return
+	}
+	var sb strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", kv[i], kv[i+1])
+	}
+	d.cfg.Log.Debugx(ctx, "http client request:%s", sb.String())
+}
+
+// isStreaming returns true for responses consumed incrementally
+func isStreaming(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/event-stream", "application/x-ndjson", "application/ndjson", "application/stream+json", "application/jsonl":
+		return true
+	}
+	return false
+}
+
+// capturingBody keeps the first max+1 bytes read from the body and hands them
+// to done on EOF or Close, whichever comes first
+type capturingBody struct {
+	io.ReadCloser
+	max  int
+	buf  bytes.Buffer
+	done func(body []byte)
+}
+
+func (c *capturingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if remaining := c.max + 1 - c.buf.Len(); remaining > 0 && n > 0 {
+		c.buf.Write(p[:min(n, remaining)])
+	}
+	if err == io.EOF {
+		c.finish()
+	}
+	return n, err
+}
+
+func (c *capturingBody) Close() error {
+	c.finish()
+	return c.ReadCloser.Close()
+}
+
+func (c *capturingBody) finish() {
+	if c.done != nil {
+		c.done(c.buf.Bytes())
+		c.done = nil
+	}
+}
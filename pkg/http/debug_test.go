@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sdinsure/agent/pkg/redact"
+)
+
+// recordingLogger keeps debug entries, it is not a StructuredLogger so entries
+// are formatted
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (r *recordingLogger) record(fmtStr string, values ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, fmt.Sprintf(fmtStr, values...))
+}
+
+func (r *recordingLogger) Entries() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.entries...)
+}
+
+func (r *recordingLogger) Debug(fmtStr string, values ...interface{}) { r.record(fmtStr, values...) }
+func (r *recordingLogger) Info(fmtStr string, values ...interface{})  {}
+func (r *recordingLogger) Warn(fmtStr string, values ...interface{})  {}
+func (r *recordingLogger) Error(fmtStr string, values ...interface{}) {}
+func (r *recordingLogger) Fatal(fmtStr string, values ...interface{}) {}
+func (r *recordingLogger) Debugx(ctx context.Context, fmtStr string, values ...interface{}) {
+	r.record(fmtStr, values...)
+}
+func (r *recordingLogger) Infox(ctx context.Context, fmtStr string, values ...interface{})  {}
+func (r *recordingLogger) Warnx(ctx context.Context, fmtStr string, values ...interface{})  {}
+func (r *recordingLogger) Errorx(ctx context.Context, fmtStr string, values ...interface{}) {}
+
+func TestDebugTransportRedacts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"tok","data":"%s"}`, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	log := &recordingLogger{}
+	client := NewHttpClient(WithDebugConfig(DebugConfig{
+		Log:          log,
+		RequestBody:  true,
+		ResponseBody: true,
+		Redactor:     redact.NewRedactor(redact.WithMaxBodyBytes(64)),
+	}))
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"password":"pw","name":"n"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	entries := log.Entries()
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+	assert.NotContains(t, entry, "secret")
+	assert.NotContains(t, entry, "pw")
+	assert.NotContains(t, entry, "session=abc")
+	assert.NotContains(t, entry, `"tok"`)
+	assert.Contains(t, entry, redact.Mask)
+	assert.Contains(t, entry, "status=200")
+	assert.Contains(t, entry, "bytes truncated")
+}
+
+func TestDebugTransportStreaming(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	log := &recordingLogger{}
+	client := NewHttpClient(WithDebugConfig(DebugConfig{Log: log, ResponseBody: true}))
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the stream is logged without its body as soon as headers arrive
+	entries := log.Entries()
+	assert.Len(t, entries, 1)
+	assert.NotContains(t, entries[0], "response_body")
+
+	buf := make([]byte, 9)
+	_, err = io.ReadFull(resp.Body, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "data: 1\n\n", string(buf))
+}
+
+func TestDebugTransportLogsUnsampledFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	log := &recordingLogger{}
+	client := NewHttpClient(WithDebugConfig(DebugConfig{Log: log, SampleRatio: 1e-12}))
+	for _, path := range []string{"/ok", "/fail"} {
+		resp, err := client.Get(srv.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	entries := log.Entries()
+	assert.Len(t, entries, 1)
+	assert.Contains(t, entries[0], "status=500")
+}
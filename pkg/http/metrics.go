@@ -0,0 +1,28 @@
+package http
+
+import (
+	"github.com/sdinsure/agent/pkg/metrics"
+)
+
+var clientNamespace = metrics.NewTypeNamespace("http_client")
+
+var (
+	metricHttpClientRetriesTotal = metrics.NewCounterVec(
+		clientNamespace,
+		metrics.NewTypeSubsystem("requests"),
+		metrics.NewTypeMetricName("retries_total"),
+		"host", "reason",
+	)
+	metricHttpClientBreakerOpen = metrics.NewUpDownCounterVec(
+		clientNamespace,
+		metrics.NewTypeSubsystem("breaker"),
+		metrics.NewTypeMetricName("open"),
+		"host",
+	)
+	metricHttpClientBreakerTransitionsTotal = metrics.NewCounterVec(
+		clientNamespace,
+		metrics.NewTypeSubsystem("breaker"),
+		metrics.NewTypeMetricName("transitions_total"),
+		"host", "state",
+	)
+)
@@ -0,0 +1,237 @@
+package openapistream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"reflect"
+	"sync"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultMaxLineSize caps a single message of a stream
+const DefaultMaxLineSize = 4 * 1024 * 1024
+
+type StreamOptioner interface {
+	apply(*StreamOptions)
+}
+
+type StreamOptions struct {
+	maxLineSize int
+}
+
+type maxLineSize struct {
+	size int
+}
+
+func (m maxLineSize) apply(o *StreamOptions) {
+	o.maxLineSize = m.size
+}
+
+// WithMaxLineSize caps a single message, longer messages fail Recv with
+// bufio.ErrTooLong
+func WithMaxLineSize(size int) maxLineSize {
+	return maxLineSize{size: size}
+}
+
+func newStreamOptions(opts ...StreamOptioner) *StreamOptions {
+	o := &StreamOptions{
+		maxLineSize: DefaultMaxLineSize,
+	}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	return o
+}
+
+// Stream reads newline delimited messages of a grpc-gateway server stream,
+// each line is an envelope of {"result": T} or {"error": google.rpc.Status}.
+// T is decoded with protojson if it is a proto.Message, with encoding/json
+// otherwise.
+type Stream[T any] struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+
+	stopCtx   func() bool
+	closeOnce sync.Once
+	closeErr  error
+	err       error
+}
+
+// NewStream reads messages from body, which is closed when ctx is done
+func NewStream[T any](ctx context.Context, body io.ReadCloser, opts ...StreamOptioner) *Stream[T] {
+	o := newStreamOptions(opts...)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, min(64*1024, o.maxLineSize)), o.maxLineSize)
+
+	s := &Stream[T]{
+		ctx:     ctx,
+		body:    body,
+		scanner: scanner,
+	}
+	// a blocked read returns once the body is closed
+	s.stopCtx = context.AfterFunc(ctx, func() { s.closeBody() })
+	return s
+}
+
+// Do sends req and returns the stream of its response, responses other than
+// 200 are returned as grpc status errors
+func Do[T any](client *http.Client, req *http.Request, opts ...StreamOptioner) (*Stream[T], error) {
+	if len(req.Header.Get("Accept")) == 0 {
+		req.Header.Set("Accept", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, int64(DefaultMaxLineSize)))
+		return nil, decodeStatusError(b, resp.StatusCode)
+	}
+	return NewStream[T](req.Context(), resp.Body, opts...), nil
+}
+
+type envelope struct {
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// Recv returns the next message, io.EOF once the stream ended, or the error
+// sent by the server as grpc status error
+func (s *Stream[T]) Recv() (T, error) {
+	var zero T
+	if s.err != nil {
+		return zero, s.err
+	}
+	msg, err := s.recv()
+	if err != nil {
+		s.err = err
+		s.Close()
+		return zero, err
+	}
+	return msg, nil
+}
+
+func (s *Stream[T]) recv() (T, error) {
+	var zero T
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var env envelope
+		if err := json.Unmarshal(line, &env); err != nil {
+			return zero, fmt.Errorf("openapistream: invalid message: %w", err)
+		}
+		if len(env.Error) > 0 && string(env.Error) != "null" {
+			return zero, decodeStatusError(env.Error, 0)
+		}
+		return decodeResult[T](env.Result)
+	}
+	if err := s.ctx.Err(); err != nil {
+		return zero, err
+	}
+	if err := s.scanner.Err(); err != nil {
+		return zero, err
+	}
+	return zero, io.EOF
+}
+
+// All iterates over the messages, the iteration stops at the first error
+// which is yielded unless it is io.EOF. the stream is closed when the
+// iteration ends.
+func (s *Stream[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer s.Close()
+		for {
+			msg, err := s.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(msg, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close closes the response body, it is safe to call more than once
+func (s *Stream[T]) Close() error {
+	s.stopCtx()
+	s.closeBody()
+	return s.closeErr
+}
+
+func (s *Stream[T]) closeBody() {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.body.Close()
+	})
+}
+
+func decodeResult[T any](raw json.RawMessage) (T, error) {
+	var msg T
+	if pm, isProto := any(msg).(proto.Message); isProto {
+		// T is a pointer to a message, allocate it
+		pm = reflect.New(reflect.TypeOf(msg).Elem()).Interface().(proto.Message)
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(raw, pm); err != nil {
+			return msg, fmt.Errorf("openapistream: invalid result: %w", err)
+		}
+		return pm.(T), nil
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return msg, fmt.Errorf("openapistream: invalid result: %w", err)
+	}
+	return msg, nil
+}
+
+// decodeStatusError decodes a google.rpc.Status, httpStatus is used when the
+// body is not a status
+func decodeStatusError(b []byte, httpStatus int) error {
+	st := &spb.Status{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, st); err == nil && st.Code != 0 {
+		return status.FromProto(st).Err()
+	}
+	// details of unknown types fail protojson, keep code and message
+	var plain struct {
+		Code    int32  `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(b, &plain); err == nil && plain.Code != 0 {
+		return status.Error(codes.Code(plain.Code), plain.Message)
+	}
+	return status.Errorf(codeFromHTTPStatus(httpStatus), "openapistream: unexpected response %d: %s", httpStatus, b)
+}
+
+func codeFromHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
@@ -0,0 +1,119 @@
+package openapistream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func newStreamServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doStream[T any](t *testing.T, ctx context.Context, url string, opts ...StreamOptioner) (*Stream[T], error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	return Do[T](http.DefaultClient, req, opts...)
+}
+
+func TestStreamRecv(t *testing.T) {
+	srv := newStreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result":{"name":"a"}}`)
+		fmt.Fprintln(w, `{"result":{"name":"b"}}`)
+		fmt.Fprintln(w, `{"error":{"code":5,"message":"gone","details":[{"@type":"type.example.com/Unknown"}]}}`)
+	})
+
+	stream, err := doStream[item](t, context.Background(), srv.URL)
+	assert.NoError(t, err)
+	var names []string
+	var streamErr error
+	for msg, err := range stream.All() {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		names = append(names, msg.Name)
+	}
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Equal(t, codes.NotFound, status.Code(streamErr))
+	assert.Equal(t, "gone", status.Convert(streamErr).Message())
+}
+
+func TestStreamProto(t *testing.T) {
+	srv := newStreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result":"hello"}`)
+	})
+
+	stream, err := doStream[*wrapperspb.StringValue](t, context.Background(), srv.URL)
+	assert.NoError(t, err)
+	defer stream.Close()
+	msg, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", msg.GetValue())
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamErrors(t *testing.T) {
+	srv := newStreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"code":7,"message":"denied"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"result":{"name":"%s"}}`+"\n", strings.Repeat("x", 100))
+	})
+
+	_, err := doStream[item](t, context.Background(), srv.URL+"/denied")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := doStream[item](t, context.Background(), srv.URL, WithMaxLineSize(32))
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestStreamCancel(t *testing.T) {
+	srv := newStreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"result":{"name":"a"}}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := doStream[item](t, ctx, srv.URL)
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := stream.Recv()
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, context.Canceled))
+	case <-time.After(5 * time.Second):
+		t.Fatal("recv is not unblocked by cancel")
+	}
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries requests failing with transport errors or RetryableStatus.
+// only idempotent methods and requests with an Idempotency-Key header are
+// retried. Retry-After of 429 and 503 responses is honored.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// RetryableStatus defaults to 429, 502, 503 and 504
+	RetryableStatus []int
+	// MaxRetryAfter caps the wait asked by Retry-After, responses asking for
+	// more are returned without retry. defaults to 30s.
+	MaxRetryAfter time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+}
+
+func WithRetry(policy RetryPolicy) Option {
+	return withRetry{policy: policy}
+}
+
+type withRetry struct {
+	policy RetryPolicy
+}
+
+func (w withRetry) apply(cfg config) config {
+	cfg.Retry = &w.policy
+	return cfg
+}
+
+type retryTransport struct {
+	t      http.RoundTripper
+	policy RetryPolicy
+}
+
+func newRetryTransport(t http.RoundTripper, policy RetryPolicy) retryTransport {
+	if len(policy.RetryableStatus) == 0 {
+		policy.RetryableStatus = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	if policy.MaxRetryAfter <= 0 {
+		policy.MaxRetryAfter = 30 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	return retryTransport{t: t, policy: policy}
+}
+
+func (r retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryable(req) {
+		return r.t.RoundTrip(req)
+	}
+	host := req.URL.Host
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+		resp, err := r.t.RoundTrip(attemptReq)
+		if attempt >= r.policy.MaxAttempts {
+			return resp, err
+		}
+
+		var wait time.Duration
+		var reason string
+		switch {
+		case err != nil:
+			if errors.Is(err, ErrCircuitOpen) || req.Context().Err() != nil {
+				return nil, err
+			}
+			wait, reason = r.backoff(attempt), "error"
+		case r.isRetryableStatus(resp.StatusCode):
+			retryAfter, hasRetryAfter := parseRetryAfter(resp)
+			if hasRetryAfter && retryAfter > r.policy.MaxRetryAfter {
+				return resp, nil
+			}
+			wait, reason = r.backoff(attempt), strconv.Itoa(resp.StatusCode)
+			if hasRetryAfter {
+				wait = retryAfter
+			}
+			// drain, so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		metricHttpClientRetriesTotal.Inc(req.Context(), host, reason)
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r retryTransport) isRetryableStatus(code int) bool {
+	for _, retryable := range r.policy.RetryableStatus {
+		if code == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the exponential backoff with full jitter
+func (r retryTransport) backoff(attempt int) time.Duration {
+	backoff := float64(r.policy.InitialBackoff) * math.Pow(r.policy.Multiplier, float64(attempt-1))
+	if max := float64(r.policy.MaxBackoff); max > 0 && backoff > max {
+		backoff = max
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// isRetryable returns true if req can be sent again safely
+func isRetryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return len(req.Header.Get("Idempotency-Key")) > 0
+}
+
+// parseRetryAfter reads Retry-After of 429 and 503 responses, in seconds or as http date
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
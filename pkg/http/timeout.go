@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WithTimeout bounds each attempt of a request including reading its body,
+// the deadline of the request context still bounds all attempts
+func WithTimeout(timeout time.Duration) Option {
+	return withTimeout{timeout: timeout}
+}
+
+type withTimeout struct {
+	timeout time.Duration
+}
+
+func (w withTimeout) apply(cfg config) config {
+	cfg.Timeout = w.timeout
+	return cfg
+}
+
+type timeoutTransport struct {
+	t       http.RoundTripper
+	timeout time.Duration
+}
+
+func (t timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.t.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the body is read after RoundTrip returns, so cancel on close
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
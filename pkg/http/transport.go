@@ -4,12 +4,12 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptrace"
-	"net/http/httputil"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
-	logger "github.com/sdinsure/agent/pkg/logger"
+	"github.com/sdinsure/agent/pkg/grpc/server/runtime"
 )
 
 func NewHttpClient(options ...Option) *http.Client {
@@ -19,47 +19,54 @@ func NewHttpClient(options ...Option) *http.Client {
 }
 
 type config struct {
-	Debug bool
-	Log   logger.Logger
+	Debug       bool
+	DebugConfig DebugConfig
 
 	HttpTrace bool
+	RequestId bool
+
+	Retry   *RetryPolicy
+	Breaker *BreakerConfig
+	Timeout time.Duration
+
+	Authenticator authenticator
 }
 
 type Option interface {
 	apply(config) config
 }
 
-func WithDebug(debug bool, log logger.Logger) Option {
-	return withDebug{debug: debug, log: log}
+func WithHttpTrace(httpTrace bool) Option {
+	return withHttpTrace{httpTrace}
 }
 
-type withDebug struct {
-	debug bool
-	log   logger.Logger
+type withHttpTrace struct {
+	httpTrace bool
 }
 
-func (w withDebug) apply(cfg config) config {
-	cfg.Debug = w.debug
-	cfg.Log = w.log
+func (w withHttpTrace) apply(cfg config) config {
+	cfg.HttpTrace = w.httpTrace
 	return cfg
 }
 
-func WithHttpTrace(httpTrace bool) Option {
-	return withHttpTrace{httpTrace}
+// WithRequestId forwards the request id in context as X-Request-Id header
+func WithRequestId(requestId bool) Option {
+	return withRequestId{requestId}
 }
 
-type withHttpTrace struct {
-	httpTrace bool
+type withRequestId struct {
+	requestId bool
 }
 
-func (w withHttpTrace) apply(cfg config) config {
-	cfg.HttpTrace = w.httpTrace
+func (w withRequestId) apply(cfg config) config {
+	cfg.RequestId = w.requestId
 	return cfg
 }
 
 func newConfig(options ...Option) (config, error) {
 	cfg := config{
 		HttpTrace: true,
+		RequestId: true,
 	}
 	for _, opt := range options {
 		cfg = opt.apply(cfg)
@@ -69,6 +76,23 @@ func newConfig(options ...Option) (config, error) {
 
 func NewHttpTransport(rt http.RoundTripper, options ...Option) http.RoundTripper {
 	cfg, _ := newConfig(options...)
+	// timeout and breaker apply per attempt, retries wrap them
+	if cfg.Timeout > 0 {
+		rt = timeoutTransport{t: rt, timeout: cfg.Timeout}
+	}
+	if cfg.Breaker != nil {
+		rt = newBreakerTransport(rt, *cfg.Breaker)
+	}
+	if cfg.Authenticator != nil {
+		// authenticate each attempt, e.g. to refresh tokens and signatures
+		rt = authTransport{t: rt, authenticator: cfg.Authenticator}
+	}
+	if cfg.Retry != nil {
+		rt = newRetryTransport(rt, *cfg.Retry)
+	}
+	if cfg.RequestId {
+		rt = requestIdTransport{t: rt}
+	}
 	if cfg.HttpTrace {
 		rt = otelhttp.NewTransport(
 			rt,
@@ -80,31 +104,20 @@ func NewHttpTransport(rt http.RoundTripper, options ...Option) http.RoundTripper
 	if !cfg.Debug {
 		return rt
 	}
-	return debugTransport{t: rt, log: cfg.Log}
+	return newDebugTransport(rt, cfg.DebugConfig)
 }
 
-type debugTransport struct {
-	t   http.RoundTripper
-	log logger.Logger
+type requestIdTransport struct {
+	t http.RoundTripper
 }
 
-func (d debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	reqDump, err := httputil.DumpRequest(req, false)
-	if err != nil {
-		return nil, err
-	}
-	d.log.Infox(req.Context(), "<---req: %s", reqDump)
-
-	resp, err := d.t.RoundTrip(req)
-	if err != nil {
-		return nil, err
-	}
-
-	respDump, err := httputil.DumpResponse(resp, false)
-	if err != nil {
-		resp.Body.Close()
-		return nil, err
+func (r requestIdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqId, found := runtime.RequestId(req.Context())
+	if !found || len(req.Header.Get(runtime.RequestIdHeader)) > 0 {
+		return r.t.RoundTrip(req)
 	}
-	d.log.Infox(req.Context(), "--->resp: %s", respDump)
-	return resp, nil
+	// RoundTripper should not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set(runtime.RequestIdHeader, reqId)
+	return r.t.RoundTrip(req)
 }
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Multiplier:     2,
+}
+
+func newCountingServer(t *testing.T, handler func(attempt int32, w http.ResponseWriter)) (*httptest.Server, *int32) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(atomic.AddInt32(&attempts, 1), w)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &attempts
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	srv, attempts := newCountingServer(t, func(attempt int32, w http.ResponseWriter) {
+		if attempt < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	client := NewHttpClient(WithRetry(testRetryPolicy))
+
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 3, atomic.LoadInt32(attempts))
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	srv, attempts := newCountingServer(t, func(attempt int32, w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client := NewHttpClient(WithRetry(testRetryPolicy))
+
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{}`))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 1, atomic.LoadInt32(attempts))
+
+	// an idempotency key makes it safe to retry
+	atomic.StoreInt32(attempts, 0)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "key")
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 3, atomic.LoadInt32(attempts))
+}
+
+func TestRetryAfter(t *testing.T) {
+	srv, attempts := newCountingServer(t, func(attempt int32, w http.ResponseWriter) {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	client := NewHttpClient(WithRetry(testRetryPolicy))
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.EqualValues(t, 2, atomic.LoadInt32(attempts))
+
+	// waits longer than MaxRetryAfter return the response
+	policy := testRetryPolicy
+	policy.MaxRetryAfter = 500 * time.Millisecond
+	atomic.StoreInt32(attempts, 0)
+	resp, err = NewHttpClient(WithRetry(policy)).Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.EqualValues(t, 1, atomic.LoadInt32(attempts))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	srv, attempts := newCountingServer(t, func(attempt int32, w http.ResponseWriter) {
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	client := NewHttpClient(WithCircuitBreaker(BreakerConfig{FailureThreshold: 2, OpenDuration: 50 * time.Millisecond}))
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err := client.Get(srv.URL)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.EqualValues(t, 2, atomic.LoadInt32(attempts))
+
+	// the probe after OpenDuration closes the circuit
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	resp, err := client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	resp, err = client.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 4, atomic.LoadInt32(attempts))
+}
+
+func TestTimeout(t *testing.T) {
+	srv, _ := newCountingServer(t, func(attempt int32, w http.ResponseWriter) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	client := NewHttpClient(WithTimeout(20 * time.Millisecond))
+
+	_, err := client.Get(srv.URL)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
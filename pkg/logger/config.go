@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	loggerflags "github.com/sdinsure/agent/pkg/logger/flags"
+)
+
+type Sink string
+
+const (
+	SinkStdout Sink = "stdout"
+	SinkStderr Sink = "stderr"
+	SinkFile   Sink = "file"
+	SinkNone   Sink = "none"
+)
+
+type Encoding string
+
+const (
+	EncodingJSON    Encoding = "json"
+	EncodingConsole Encoding = "console"
+	EncodingLogfmt  Encoding = "logfmt"
+)
+
+type Config struct {
+	Level Level
+
+	// Sinks are where logs are written. when both stdout and stderr are
+	// chosen, errors go to stderr only and the rest go to stdout.
+	Sinks    []Sink
+	Encoding Encoding
+
+	File     FileConfig
+	Sampling *SamplingConfig
+}
+
+// FileConfig is used by SinkFile
+type FileConfig struct {
+	// Dir defaults to the --log_dir flag
+	Dir string
+	// Name defaults to NewLogName(AppLog{})
+	Name string
+
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+}
+
+// SamplingConfig logs the first Initial entries with the same level and
+// message in each Tick, then every Thereafter-th of them
+type SamplingConfig struct {
+	Initial    int
+	Thereafter int
+	Tick       time.Duration
+}
+
+// NewConfigFromFlags returns the config built from loggerflags, which is what
+// NewLogger uses
+func NewConfigFromFlags(verbose bool) (Config, error) {
+	level := InfoLevel
+	if verbose {
+		level = DebugLevel
+	}
+	var sinks []Sink
+	for _, sink := range strings.Split(loggerflags.GetLogSinks(), ",") {
+		sink = strings.TrimSpace(sink)
+		if len(sink) > 0 {
+			sinks = append(sinks, Sink(sink))
+		}
+	}
+	cfg := Config{
+		Level:    level,
+		Sinks:    sinks,
+		Encoding: Encoding(loggerflags.GetLogEncoder()),
+		File: FileConfig{
+			Dir:        loggerflags.GetLogDir(),
+			MaxSizeMB:  loggerflags.GetLogMaxSizeMB(),
+			MaxBackups: loggerflags.GetLogMaxBackups(),
+			MaxAgeDays: loggerflags.GetLogMaxAgeDays(),
+			Compress:   loggerflags.GetLogCompress(),
+		},
+	}
+	if loggerflags.GetLogSamplingInitial() > 0 {
+		cfg.Sampling = &SamplingConfig{
+			Initial:    loggerflags.GetLogSamplingInitial(),
+			Thereafter: loggerflags.GetLogSamplingThereafter(),
+			Tick:       time.Second,
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	for _, sink := range c.Sinks {
+		switch sink {
+		case SinkStdout, SinkStderr, SinkFile, SinkNone:
+		default:
+			return fmt.Errorf("logger: unknown sink %q", sink)
+		}
+	}
+	switch c.Encoding {
+	case EncodingJSON, EncodingConsole, EncodingLogfmt, "":
+	default:
+		return fmt.Errorf("logger: unknown encoding %q", c.Encoding)
+	}
+	if c.Sampling != nil && c.Sampling.Initial <= 0 {
+		return fmt.Errorf("logger: sampling initial should be positive")
+	}
+	return nil
+}
+
+func (c Config) hasSink(sink Sink) bool {
+	for _, s := range c.Sinks {
+		if s == sink {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Config) newEncoder() zapcore.Encoder {
+	encConfig := zap.NewProductionEncoderConfig()
+	encConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
+
+	switch c.Encoding {
+	case EncodingConsole:
+		return zapcore.NewConsoleEncoder(encConfig)
+	case EncodingLogfmt:
+		return newLogfmtEncoder(encConfig)
+	}
+	return zapcore.NewJSONEncoder(encConfig)
+}
+
+func (c Config) newFileWriter() zapcore.WriteSyncer {
+	return NewFileWriter(c.File)
+}
+
+// NewFileWriter returns a rotated file writer, which can also be used as a
+// separate sink, e.g. for access logs
+func NewFileWriter(cfg FileConfig) zapcore.WriteSyncer {
+	dir := cfg.Dir
+	if len(dir) == 0 {
+		dir = loggerflags.GetLogDir()
+	}
+	name := cfg.Name
+	if len(name) == 0 {
+		name = NewLogName(AppLog{})
+	}
+	return zapcore.AddSync(&lumberjack.Logger{
+		Filename:   filepath.Join(dir, name),
+		MaxSize:    cfg.MaxSizeMB, // megabytes
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays, // days
+		Compress:   cfg.Compress,
+	})
+}
+
+// newCore builds the cores of sinks, levels are enforced by levelFilterCore
+// so every core here accepts all levels
+func (c Config) newCore() zapcore.Core {
+	var cores []zapcore.Core
+
+	allLevels := zapcore.DebugLevel
+	belowError := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+		return level < zapcore.ErrorLevel
+	})
+	errorAndAbove := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+		return level >= zapcore.ErrorLevel
+	})
+
+	stdout, stderr := c.hasSink(SinkStdout), c.hasSink(SinkStderr)
+	switch {
+	case stdout && stderr:
+		// write info to stdout
+		// and error/fatal to stderr
+		cores = append(cores,
+			zapcore.NewCore(c.newEncoder(), zapcore.Lock(os.Stdout), belowError),
+			zapcore.NewCore(c.newEncoder(), zapcore.Lock(os.Stderr), errorAndAbove),
+		)
+	case stdout:
+		cores = append(cores, zapcore.NewCore(c.newEncoder(), zapcore.Lock(os.Stdout), allLevels))
+	case stderr:
+		cores = append(cores, zapcore.NewCore(c.newEncoder(), zapcore.Lock(os.Stderr), allLevels))
+	}
+	if c.hasSink(SinkFile) {
+		cores = append(cores, zapcore.NewCore(c.newEncoder(), c.newFileWriter(), allLevels))
+	}
+	core := zapcore.NewTee(cores...)
+
+	if c.Sampling != nil {
+		tick := c.Sampling.Tick
+		if tick <= 0 {
+			tick = time.Second
+		}
+		core = zapcore.NewSamplerWithOptions(core, tick, c.Sampling.Initial, c.Sampling.Thereafter)
+	}
+	return core
+}
+
+// NewLoggerWithConfig returns a logger writing to the sinks of cfg
+func NewLoggerWithConfig(cfg Config) (*loggerImpl, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newLoggerImpl(cfg.newCore(), NewLevelController(cfg.Level)), nil
+}
+
+func newLoggerImpl(core zapcore.Core, levels *LevelController) *loggerImpl {
+	zapLogger := zap.New(&levelFilterCore{Core: core, enabler: levels.enabler("")}, zap.AddCallerSkip(2))
+	return &loggerImpl{
+		Logger: zapLogger,
+		levels: levels,
+	}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Sinks: []Sink{SinkStdout, SinkFile}, Encoding: EncodingLogfmt}.Validate())
+	assert.Error(t, Config{Sinks: []Sink{"syslog"}}.Validate())
+	assert.Error(t, Config{Encoding: "xml"}.Validate())
+	assert.Error(t, Config{Sampling: &SamplingConfig{}}.Validate())
+}
+
+func TestLoggerWithFileSink(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLoggerWithConfig(Config{
+		Level:    InfoLevel,
+		Sinks:    []Sink{SinkFile},
+		Encoding: EncodingLogfmt,
+		File:     FileConfig{Dir: dir, Name: "app.log", MaxSizeMB: 1},
+		Sampling: &SamplingConfig{Initial: 2, Thereafter: 0, Tick: time.Minute},
+	})
+	assert.NoError(t, err)
+
+	l.Debug("dropped")
+	for i := 0; i < 5; i++ {
+		l.Info("repeated")
+	}
+	l.WithFields(String("key", "a value")).Named("comp").Warn("done")
+	l.Sync()
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	content := string(b)
+	assert.NotContains(t, content, "dropped")
+	assert.Equal(t, 2, strings.Count(content, "msg=repeated"))
+	assert.Contains(t, content, `key="a value"`)
+	assert.Contains(t, content, "level=warn")
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	enc := newLogfmtEncoder(zap.NewProductionEncoderConfig())
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zapcore.InfoLevel,
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message: "hello world",
+	}, []zapcore.Field{zap.Int("b", 1), zap.String("a", "x=y"), zap.Strings("c", []string{"d"})})
+	assert.NoError(t, err)
+	assert.Equal(t, `ts=2024-01-02T03:04:05Z level=info msg="hello world" a="x=y" b=1 c="[\"d\"]"`+"\n", buf.String())
+}
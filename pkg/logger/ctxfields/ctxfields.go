@@ -0,0 +1,27 @@
+// Package ctxfields keeps the request values written to logs in context. it
+// is shared by the logger and the grpc server runtime, so the logger does not
+// depend on the server.
+package ctxfields
+
+import "context"
+
+type subjectKey struct{}
+type requestIdKey struct{}
+
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+func Subject(ctx context.Context) (string, bool) {
+	subject, found := ctx.Value(subjectKey{}).(string)
+	return subject, found
+}
+
+func WithRequestId(ctx context.Context, reqId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, reqId)
+}
+
+func RequestId(ctx context.Context) (string, bool) {
+	reqId, found := ctx.Value(requestIdKey{}).(string)
+	return reqId, found
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Field is a typed key-value pair attached to a structured log entry
+type Field = zapcore.Field
+
+func String(key string, value string) Field {
+	return zap.String(key, value)
+}
+
+func Strings(key string, values []string) Field {
+	return zap.Strings(key, values)
+}
+
+func Int(key string, value int) Field {
+	return zap.Int(key, value)
+}
+
+func Int64(key string, value int64) Field {
+	return zap.Int64(key, value)
+}
+
+func Float64(key string, value float64) Field {
+	return zap.Float64(key, value)
+}
+
+func Bool(key string, value bool) Field {
+	return zap.Bool(key, value)
+}
+
+func Duration(key string, value time.Duration) Field {
+	return zap.Duration(key, value)
+}
+
+func Time(key string, value time.Time) Field {
+	return zap.Time(key, value)
+}
+
+// Err adds err under the "error" key
+func Err(err error) Field {
+	return zap.Error(err)
+}
+
+func Any(key string, value interface{}) Field {
+	return zap.Any(key, value)
+}
@@ -7,12 +7,29 @@ import (
 
 var (
 	logDir = new(string)
+
+	logSinks              = stringPtr("stdout,stderr,file")
+	logEncoder            = stringPtr("json")
+	logMaxSizeMB          = intPtr(500)
+	logMaxBackups         = intPtr(3)
+	logMaxAgeDays         = intPtr(28)
+	logCompress           = new(bool)
+	logSamplingInitial    = new(int)
+	logSamplingThereafter = new(int)
 )
 
 // logDirs contains a list of candidates of dir for log
 
 func InitFlags() {
 	logDir = flag.String("log_dir", "", "If non-empty, write log files in this directory")
+	logSinks = flag.String("log_sinks", *logSinks, "comma separated log sinks: stdout, stderr, file or none")
+	logEncoder = flag.String("log_encoder", *logEncoder, "log encoder: json, console or logfmt")
+	logMaxSizeMB = flag.Int("log_max_size_mb", *logMaxSizeMB, "max size in megabytes of a log file before it is rotated")
+	logMaxBackups = flag.Int("log_max_backups", *logMaxBackups, "max number of rotated log files to retain")
+	logMaxAgeDays = flag.Int("log_max_age_days", *logMaxAgeDays, "max days to retain rotated log files")
+	logCompress = flag.Bool("log_compress", *logCompress, "gzip rotated log files")
+	logSamplingInitial = flag.Int("log_sampling_initial", *logSamplingInitial, "log the first N entries with the same message per second, 0 disables sampling")
+	logSamplingThereafter = flag.Int("log_sampling_thereafter", *logSamplingThereafter, "after log_sampling_initial, log every Mth entry with the same message per second")
 }
 
 func GetLogDir() string {
@@ -21,3 +38,43 @@ func GetLogDir() string {
 	}
 	return os.TempDir()
 }
+
+func GetLogSinks() string {
+	return *logSinks
+}
+
+func GetLogEncoder() string {
+	return *logEncoder
+}
+
+func GetLogMaxSizeMB() int {
+	return *logMaxSizeMB
+}
+
+func GetLogMaxBackups() int {
+	return *logMaxBackups
+}
+
+func GetLogMaxAgeDays() int {
+	return *logMaxAgeDays
+}
+
+func GetLogCompress() bool {
+	return *logCompress
+}
+
+func GetLogSamplingInitial() int {
+	return *logSamplingInitial
+}
+
+func GetLogSamplingThereafter() int {
+	return *logSamplingThereafter
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
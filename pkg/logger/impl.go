@@ -3,24 +3,33 @@ package logger
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
-	"time"
 
 	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
 
-	loggerflags "github.com/sdinsure/agent/pkg/logger/flags"
+	"github.com/sdinsure/agent/pkg/logger/ctxfields"
+)
+
+// keys of the fields extracted from context
+const (
+	requestIdKey = "request_id"
+	subjectKey   = "subject"
+	traceIdKey   = "trace_id"
+	spanIdKey    = "span_id"
 )
 
 var (
-	_ Logger = &loggerImpl{}
+	_ Logger           = &loggerImpl{}
+	_ StructuredLogger = &loggerImpl{}
 )
 
 type loggerImpl struct {
 	*zap.Logger
+
+	// levels is nil for loggers not created by NewLogger
+	levels *LevelController
 }
 
 func (l *loggerImpl) Flush() error {
@@ -49,12 +58,31 @@ func (l *loggerImpl) Fatal(fmtStr string, values ...interface{}) {
 }
 
 func (l *loggerImpl) attachCtx(ctx context.Context) *loggerImpl {
+	return &loggerImpl{Logger: l.Logger.With(contextFields(ctx)...), levels: l.levels}
+}
+
+// contextFields collects ctxtags, request id, subject and trace/span ids from ctx
+func contextFields(ctx context.Context) []zapcore.Field {
 	fields := []zapcore.Field{}
-	tags := grpc_ctxtags.Extract(ctx)
-	for k, v := range tags.Values() {
+	tags := grpc_ctxtags.Extract(ctx).Values()
+	for k, v := range tags {
 		fields = append(fields, zap.Any(k, v))
 	}
-	return &loggerImpl{Logger: l.Logger.With(fields...)}
+	if _, tagged := tags[requestIdKey]; !tagged {
+		if reqId, found := ctxfields.RequestId(ctx); found {
+			fields = append(fields, zap.String(requestIdKey, reqId))
+		}
+	}
+	if sub, found := ctxfields.Subject(ctx); found {
+		fields = append(fields, zap.String(subjectKey, sub))
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		fields = append(fields,
+			zap.String(traceIdKey, spanCtx.TraceID().String()),
+			zap.String(spanIdKey, spanCtx.SpanID().String()),
+		)
+	}
+	return fields
 }
 
 func (l *loggerImpl) Debugx(ctx context.Context, fmtStr string, values ...interface{}) {
@@ -73,6 +101,38 @@ func (l *loggerImpl) Errorx(ctx context.Context, fmtStr string, values ...interf
 	l.attachCtx(ctx).Error(fmtStr, values...)
 }
 
+func (l *loggerImpl) WithFields(fields ...Field) StructuredLogger {
+	return &loggerImpl{Logger: l.Logger.With(fields...), levels: l.levels}
+}
+
+// Named returns a logger of component, whose level can be changed apart from
+// the global level via its LevelController
+func (l *loggerImpl) Named(component string) StructuredLogger {
+	named := l.Logger.Named(component)
+	if filterCore, isFiltered := named.Core().(*levelFilterCore); isFiltered && l.levels != nil {
+		named = named.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+			return &levelFilterCore{Core: filterCore.Core, enabler: l.levels.enabler(component)}
+		}))
+	}
+	return &loggerImpl{Logger: named, levels: l.levels}
+}
+
+func (l *loggerImpl) DebugKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.attachCtx(ctx).Logger.Sugar().Debugw(msg, kv...)
+}
+
+func (l *loggerImpl) InfoKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.attachCtx(ctx).Logger.Sugar().Infow(msg, kv...)
+}
+
+func (l *loggerImpl) WarnKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.attachCtx(ctx).Logger.Sugar().Warnw(msg, kv...)
+}
+
+func (l *loggerImpl) ErrorKV(ctx context.Context, msg string, kv ...interface{}) {
+	l.attachCtx(ctx).Logger.Sugar().Errorw(msg, kv...)
+}
+
 type LogTag interface {
 	name() string
 }
@@ -93,50 +153,12 @@ func NewLogName(tag LogTag) string {
 	return name
 }
 
+// NewLogger returns a logger configured by loggerflags (see NewConfigFromFlags).
+// it panics on invalid flags.
 func NewLogger(verbose bool) *loggerImpl {
-	encConfig := zap.NewProductionEncoderConfig()
-	encConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
-
-	var defaultLevelEnabler zap.LevelEnablerFunc
-	if verbose {
-		defaultLevelEnabler = zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-			return level >= zapcore.DebugLevel
-		})
-	} else {
-		defaultLevelEnabler = zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-			return level >= zapcore.InfoLevel
-		})
-	}
-	errorFatalLevel := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-		return level == zapcore.ErrorLevel || level == zapcore.FatalLevel
-	})
-	core := zapcore.NewTee(
-		// write info to stdout
-		// and stderr/fatal to stderr
-		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encConfig),
-			zapcore.Lock(os.Stdout),
-			defaultLevelEnabler,
-		),
-		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encConfig),
-			zapcore.Lock(os.Stderr),
-			errorFatalLevel,
-		),
-		// also write all logs to applog file
-		zapcore.NewCore(
-			zapcore.NewJSONEncoder(encConfig),
-			zapcore.AddSync(&lumberjack.Logger{
-				Filename:   filepath.Join(loggerflags.GetLogDir(), NewLogName(AppLog{})),
-				MaxSize:    500, // megabytes
-				MaxBackups: 3,
-				MaxAge:     28, // days
-			}),
-			defaultLevelEnabler,
-		),
-	)
-	zapLogger := zap.New(core, zap.AddCallerSkip(2))
-	return &loggerImpl{
-		Logger: zapLogger,
+	cfg, err := NewConfigFromFlags(verbose)
+	if err != nil {
+		panic(err)
 	}
+	return newLoggerImpl(cfg.newCore(), NewLevelController(cfg.Level))
 }
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	grpc_ctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sdinsure/agent/pkg/logger/ctxfields"
+)
+
+func newObservedLogger() (*loggerImpl, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return &loggerImpl{Logger: zap.New(core)}, logs
+}
+
+func TestInfoKV(t *testing.T) {
+	l, logs := newObservedLogger()
+
+	l.WithFields(String("component", "test")).InfoKV(context.Background(), "hello", "count", 3, Bool("ok", true))
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.EqualValues(t, "hello", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.EqualValues(t, "test", fields["component"])
+	assert.EqualValues(t, 3, fields["count"])
+	assert.EqualValues(t, true, fields["ok"])
+
+	// With of the embedded zap.Logger is kept
+	l.With(String("component", "zap")).Info("zap")
+	assert.EqualValues(t, "zap", logs.All()[1].ContextMap()["component"])
+}
+
+func TestInfoxWithContextFields(t *testing.T) {
+	l, logs := newObservedLogger()
+
+	traceId, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanId, _ := trace.SpanIDFromHex("0102030405060708")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+	ctx = ctxfields.WithRequestId(ctx, "req-1")
+	ctx = ctxfields.WithSubject(ctx, "user-1")
+
+	l.Infox(ctx, "hello %s", "world")
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.EqualValues(t, "hello world", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.EqualValues(t, "req-1", fields["request_id"])
+	assert.EqualValues(t, "user-1", fields["subject"])
+	assert.EqualValues(t, traceId.String(), fields["trace_id"])
+	assert.EqualValues(t, spanId.String(), fields["span_id"])
+}
+
+func TestRequestIdNotDuplicatedWithTags(t *testing.T) {
+	l, logs := newObservedLogger()
+
+	ctx := ctxfields.WithRequestId(context.Background(), "req-1")
+	ctx = grpc_ctxtags.SetInContext(ctx, grpc_ctxtags.NewTags().Set("request_id", "req-1"))
+	l.Infox(ctx, "hello")
+
+	count := 0
+	for _, field := range logs.All()[0].Context {
+		if field.Key == "request_id" {
+			count++
+		}
+	}
+	assert.EqualValues(t, 1, count)
+}
@@ -0,0 +1,217 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Level = zapcore.Level
+
+const (
+	DebugLevel = zapcore.DebugLevel
+	InfoLevel  = zapcore.InfoLevel
+	WarnLevel  = zapcore.WarnLevel
+	ErrorLevel = zapcore.ErrorLevel
+)
+
+// ParseLevel parses level names like "debug" or "info"
+func ParseLevel(s string) (Level, error) {
+	return zapcore.ParseLevel(s)
+}
+
+// NewLevelController returns a controller of the global level and per component
+// levels, which can be changed at runtime. a component without its own level
+// follows the global one.
+func NewLevelController(level Level) *LevelController {
+	return &LevelController{
+		global:     zap.NewAtomicLevelAt(level),
+		components: make(map[string]*componentLevel),
+		reverts:    make(map[string]*pendingRevert),
+	}
+}
+
+type LevelController struct {
+	global zap.AtomicLevel
+
+	mu         sync.Mutex
+	components map[string]*componentLevel
+	// reverts holds pending auto-reverts, "" is for the global level
+	reverts map[string]*pendingRevert
+}
+
+// pendingRevert restores the level before the first override of a ttl chain,
+// so extending an override does not make it permanent
+type pendingRevert struct {
+	timer  *time.Timer
+	revert func()
+}
+
+type componentLevel struct {
+	set   atomic.Bool
+	level zap.AtomicLevel
+}
+
+func (c *LevelController) Level() Level {
+	return c.global.Level()
+}
+
+// SetLevel changes the global level. with ttl > 0, the previous level is
+// restored after ttl. calling it again while a revert is pending extends the
+// override, the level before the first override is restored.
+func (c *LevelController) SetLevel(level Level, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.global.Level()
+	c.global.SetLevel(level)
+	c.scheduleRevertLocked("", ttl, func() {
+		c.global.SetLevel(previous)
+	})
+}
+
+// ComponentLevel returns the level of component, false if it follows the global level
+func (c *LevelController) ComponentLevel(component string) (Level, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl, found := c.components[component]
+	if !found || !cl.set.Load() {
+		return c.global.Level(), false
+	}
+	return cl.level.Level(), true
+}
+
+// SetComponentLevel changes the level of component. with ttl > 0, the previous
+// level is restored after ttl, as in SetLevel.
+func (c *LevelController) SetComponentLevel(component string, level Level, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl := c.componentLocked(component)
+	previousSet, previous := cl.set.Load(), cl.level.Level()
+	cl.level.SetLevel(level)
+	cl.set.Store(true)
+	c.scheduleRevertLocked(component, ttl, func() {
+		cl.level.SetLevel(previous)
+		cl.set.Store(previousSet)
+	})
+}
+
+// UnsetComponentLevel makes component follow the global level again
+func (c *LevelController) UnsetComponentLevel(component string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pending, found := c.reverts[component]; found {
+		pending.timer.Stop()
+		delete(c.reverts, component)
+	}
+	if cl, found := c.components[component]; found {
+		cl.set.Store(false)
+	}
+}
+
+// Levels returns the global level and levels of components having their own
+func (c *LevelController) Levels() (Level, map[string]Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	components := make(map[string]Level)
+	for name, cl := range c.components {
+		if cl.set.Load() {
+			components[name] = cl.level.Level()
+		}
+	}
+	return c.global.Level(), components
+}
+
+func (c *LevelController) componentLocked(component string) *componentLevel {
+	cl, found := c.components[component]
+	if !found {
+		cl = &componentLevel{level: zap.NewAtomicLevelAt(c.global.Level())}
+		c.components[component] = cl
+	}
+	return cl
+}
+
+// scheduleRevertLocked runs revert after ttl. a pending revert of key is
+// rescheduled instead, keeping the level it restores. ttl <= 0 drops it, the
+// change is permanent.
+func (c *LevelController) scheduleRevertLocked(key string, ttl time.Duration, revert func()) {
+	if pending, found := c.reverts[key]; found {
+		pending.timer.Stop()
+		delete(c.reverts, key)
+		revert = pending.revert
+	}
+	if ttl <= 0 {
+		return
+	}
+	pending := &pendingRevert{revert: revert}
+	pending.timer = time.AfterFunc(ttl, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// skip if replaced by a later change
+		if c.reverts[key] != pending {
+			return
+		}
+		delete(c.reverts, key)
+		pending.revert()
+	})
+	c.reverts[key] = pending
+}
+
+// enabler is checked on every log call, so it must stay lock free
+func (c *LevelController) enabler(component string) zapcore.LevelEnabler {
+	if component == "" {
+		return c.global
+	}
+	c.mu.Lock()
+	cl := c.componentLocked(component)
+	c.mu.Unlock()
+	return zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+		if cl.set.Load() {
+			return cl.level.Enabled(level)
+		}
+		return c.global.Enabled(level)
+	})
+}
+
+// levelFilterCore gates the wrapped core with a LevelController's enabler
+type levelFilterCore struct {
+	zapcore.Core
+	enabler zapcore.LevelEnabler
+}
+
+func (l *levelFilterCore) Enabled(level zapcore.Level) bool {
+	return l.enabler.Enabled(level)
+}
+
+func (l *levelFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelFilterCore{Core: l.Core.With(fields), enabler: l.enabler}
+}
+
+func (l *levelFilterCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !l.enabler.Enabled(entry.Level) {
+		return checked
+	}
+	return l.Core.Check(entry, checked)
+}
+
+// GetLevelController returns the controller of loggers created by NewLogger
+func GetLevelController(l Logger) (*LevelController, bool) {
+	switch sampled := l.(type) {
+	case *sampledLogger:
+		return GetLevelController(sampled.Logger)
+	case *sampledStructuredLogger:
+		return GetLevelController(sampled.structured)
+	}
+	impl, isLoggerImpl := l.(*loggerImpl)
+	if !isLoggerImpl || impl.levels == nil {
+		return nil, false
+	}
+	return impl.levels, true
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newObservedLevelLogger(level Level) (*loggerImpl, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	levels := NewLevelController(level)
+	return &loggerImpl{
+		Logger: zap.New(&levelFilterCore{Core: core, enabler: levels.enabler("")}),
+		levels: levels,
+	}, logs
+}
+
+func TestGlobalLevel(t *testing.T) {
+	l, logs := newObservedLevelLogger(InfoLevel)
+
+	l.Debug("dropped")
+	l.levels.SetLevel(DebugLevel, 0)
+	l.Debug("kept")
+
+	assert.Len(t, logs.All(), 1)
+	assert.EqualValues(t, "kept", logs.All()[0].Message)
+}
+
+func TestComponentLevel(t *testing.T) {
+	l, logs := newObservedLevelLogger(InfoLevel)
+	authz := l.Named("authz")
+
+	l.levels.SetComponentLevel("authz", DebugLevel, 0)
+	authz.Debug("authz debug")
+	l.Debug("global debug")
+	assert.Len(t, logs.All(), 1)
+	assert.EqualValues(t, "authz debug", logs.All()[0].Message)
+
+	_, components := l.levels.Levels()
+	assert.EqualValues(t, map[string]Level{"authz": DebugLevel}, components)
+
+	// follows the global level again
+	l.levels.UnsetComponentLevel("authz")
+	authz.Debug("dropped")
+	assert.Len(t, logs.All(), 1)
+}
+
+func TestLevelRevertsAfterTTL(t *testing.T) {
+	levels := NewLevelController(InfoLevel)
+
+	levels.SetLevel(DebugLevel, 50*time.Millisecond)
+	levels.SetComponentLevel("authz", ErrorLevel, 50*time.Millisecond)
+	assert.EqualValues(t, DebugLevel, levels.Level())
+
+	assert.Eventually(t, func() bool {
+		_, set := levels.ComponentLevel("authz")
+		return levels.Level() == InfoLevel && !set
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLevelTTLExtended(t *testing.T) {
+	levels := NewLevelController(InfoLevel)
+
+	for i := 0; i < 3; i++ {
+		levels.SetLevel(DebugLevel, 50*time.Millisecond)
+		levels.SetComponentLevel("authz", DebugLevel, 50*time.Millisecond)
+	}
+	assert.EqualValues(t, DebugLevel, levels.Level())
+
+	// the levels before the first override are restored
+	assert.Eventually(t, func() bool {
+		_, set := levels.ComponentLevel("authz")
+		return levels.Level() == InfoLevel && !set
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ zapcore.Encoder = &logfmtEncoder{}
+
+	logfmtPool = buffer.NewPool()
+)
+
+// logfmtEncoder writes entries as key=value pairs. entry keys come first,
+// followed by fields sorted by key. nested values are written as json.
+type logfmtEncoder struct {
+	*zapcore.MapObjectEncoder
+	config zapcore.EncoderConfig
+}
+
+func newLogfmtEncoder(config zapcore.EncoderConfig) *logfmtEncoder {
+	return &logfmtEncoder{
+		MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+		config:           config,
+	}
+}
+
+func (l *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := newLogfmtEncoder(l.config)
+	for key, value := range l.Fields {
+		clone.Fields[key] = value
+	}
+	return clone
+}
+
+func (l *logfmtEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	enc := l.Clone().(*logfmtEncoder)
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	buf := logfmtPool.Get()
+	if len(l.config.TimeKey) > 0 {
+		appendLogfmt(buf, l.config.TimeKey, entry.Time.Format(time.RFC3339))
+	}
+	if len(l.config.LevelKey) > 0 {
+		appendLogfmt(buf, l.config.LevelKey, entry.Level.String())
+	}
+	if len(l.config.NameKey) > 0 && len(entry.LoggerName) > 0 {
+		appendLogfmt(buf, l.config.NameKey, entry.LoggerName)
+	}
+	if len(l.config.CallerKey) > 0 && entry.Caller.Defined {
+		appendLogfmt(buf, l.config.CallerKey, entry.Caller.TrimmedPath())
+	}
+	if len(l.config.MessageKey) > 0 {
+		appendLogfmt(buf, l.config.MessageKey, entry.Message)
+	}
+
+	keys := make([]string, 0, len(enc.Fields))
+	for key := range enc.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		appendLogfmt(buf, key, enc.Fields[key])
+	}
+
+	if len(l.config.StacktraceKey) > 0 && len(entry.Stack) > 0 {
+		appendLogfmt(buf, l.config.StacktraceKey, entry.Stack)
+	}
+	buf.AppendString(zapcore.DefaultLineEnding)
+	return buf, nil
+}
+
+func appendLogfmt(buf *buffer.Buffer, key string, value interface{}) {
+	if buf.Len() > 0 {
+		buf.AppendByte(' ')
+	}
+	buf.AppendString(key)
+	buf.AppendByte('=')
+	buf.AppendString(logfmtValue(value))
+}
+
+func logfmtValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case time.Time:
+		s = v.Format(time.RFC3339)
+	case time.Duration:
+		s = v.String()
+	case fmt.Stringer:
+		s = v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+		s = fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			s = fmt.Sprint(v)
+		} else {
+			s = string(b)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -14,3 +14,20 @@ type Logger interface {
 	Warnx(ctx context.Context, fmtStr string, values ...interface{})
 	Errorx(ctx context.Context, fmtStr string, values ...interface{})
 }
+
+// StructuredLogger writes field based entries, which stay queryable in json logs.
+// kv accepts alternating keys and values, as well as Field values.
+type StructuredLogger interface {
+	Logger
+
+	// WithFields returns a logger adding fields to every entry. it is not
+	// named With, which is the zap.Logger method of loggers by NewLogger
+	WithFields(fields ...Field) StructuredLogger
+	// Named returns a logger for component, e.g. "authz"
+	Named(component string) StructuredLogger
+
+	DebugKV(ctx context.Context, msg string, kv ...interface{})
+	InfoKV(ctx context.Context, msg string, kv ...interface{})
+	WarnKV(ctx context.Context, msg string, kv ...interface{})
+	ErrorKV(ctx context.Context, msg string, kv ...interface{})
+}
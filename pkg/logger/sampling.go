@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	_ Logger           = &sampledLogger{}
+	_ StructuredLogger = &sampledStructuredLogger{}
+)
+
+// DefaultSampling is used by built-in middlewares and resolvers logging on
+// every request, i.e. the authn and authz middlewares and the identity and
+// project resolvers, so their logs stay readable under load
+var DefaultSampling = SamplingConfig{
+	Initial:    10,
+	Thereafter: 100,
+	Tick:       time.Second,
+}
+
+// Sampled returns a Logger writing the first cfg.Initial Debug/Info entries of
+// each format string per cfg.Tick, then every cfg.Thereafter-th of them.
+// entries are keyed by the format string rather than the formatted message, so
+// per request values do not defeat sampling. Warn and above are never dropped.
+// a StructuredLogger is returned as a StructuredLogger, loggers derived by With
+// share its sampling.
+//
+// a logger already returned by Sampled is returned as is, so callers can pick
+// the rate of middlewares sampling by default by passing their own.
+func Sampled(l Logger, cfg SamplingConfig) Logger {
+	switch l.(type) {
+	case *sampledLogger, *sampledStructuredLogger:
+		return l
+	}
+	if cfg.Tick <= 0 {
+		cfg.Tick = time.Second
+	}
+	sampled := &sampledLogger{Logger: l, sampler: &sampler{cfg: cfg}}
+	if structured, isStructured := l.(StructuredLogger); isStructured {
+		return &sampledStructuredLogger{sampledLogger: sampled, structured: structured}
+	}
+	return sampled
+}
+
+// sampler counts entries by level and format string, which are constants of
+// the code, so the counters stay bounded
+type sampler struct {
+	cfg      SamplingConfig
+	counters sync.Map
+}
+
+type samplerKey struct {
+	level  Level
+	fmtStr string
+}
+
+type samplerCounter struct {
+	resetAt atomic.Int64
+	n       atomic.Uint64
+}
+
+func (s *sampler) allow(level Level, fmtStr string) bool {
+	key := samplerKey{level: level, fmtStr: fmtStr}
+	value, found := s.counters.Load(key)
+	if !found {
+		value, _ = s.counters.LoadOrStore(key, &samplerCounter{})
+	}
+	counter := value.(*samplerCounter)
+
+	now := time.Now().UnixNano()
+	resetAt := counter.resetAt.Load()
+	var n uint64
+	if now > resetAt && counter.resetAt.CompareAndSwap(resetAt, now+s.cfg.Tick.Nanoseconds()) {
+		counter.n.Store(1)
+		n = 1
+	} else {
+		n = counter.n.Add(1)
+	}
+	initial := uint64(s.cfg.Initial)
+	if n <= initial {
+		return true
+	}
+	return s.cfg.Thereafter > 0 && (n-initial)%uint64(s.cfg.Thereafter) == 0
+}
+
+type sampledLogger struct {
+	Logger
+	sampler *sampler
+}
+
+func (s *sampledLogger) Debug(fmtStr string, values ...interface{}) {
+	if s.sampler.allow(DebugLevel, fmtStr) {
+		s.Logger.Debug(fmtStr, values...)
+	}
+}
+
+func (s *sampledLogger) Info(fmtStr string, values ...interface{}) {
+	if s.sampler.allow(InfoLevel, fmtStr) {
+		s.Logger.Info(fmtStr, values...)
+	}
+}
+
+func (s *sampledLogger) Debugx(ctx context.Context, fmtStr string, values ...interface{}) {
+	if s.sampler.allow(DebugLevel, fmtStr) {
+		s.Logger.Debugx(ctx, fmtStr, values...)
+	}
+}
+
+func (s *sampledLogger) Infox(ctx context.Context, fmtStr string, values ...interface{}) {
+	if s.sampler.allow(InfoLevel, fmtStr) {
+		s.Logger.Infox(ctx, fmtStr, values...)
+	}
+}
+
+// zapLogger returns the unsampled zap logger of the wrapped Logger
+func (s *sampledLogger) zapLogger() *zap.Logger {
+	return GetUnderlyingZapLoggerOrDie(s.Logger)
+}
+
+// sampledStructuredLogger samples KV entries by message
+type sampledStructuredLogger struct {
+	*sampledLogger
+	structured StructuredLogger
+}
+
+func (s *sampledStructuredLogger) WithFields(fields ...Field) StructuredLogger {
+	with := s.structured.WithFields(fields...)
+	return &sampledStructuredLogger{
+		sampledLogger: &sampledLogger{Logger: with, sampler: s.sampler},
+		structured:    with,
+	}
+}
+
+// Named returns a sampled logger of component, which is sampled apart from
+// its parent
+func (s *sampledStructuredLogger) Named(component string) StructuredLogger {
+	return Sampled(s.structured.Named(component), s.sampler.cfg).(StructuredLogger)
+}
+
+func (s *sampledStructuredLogger) DebugKV(ctx context.Context, msg string, kv ...interface{}) {
+	if s.sampler.allow(DebugLevel, msg) {
+		s.structured.DebugKV(ctx, msg, kv...)
+	}
+}
+
+func (s *sampledStructuredLogger) InfoKV(ctx context.Context, msg string, kv ...interface{}) {
+	if s.sampler.allow(InfoLevel, msg) {
+		s.structured.InfoKV(ctx, msg, kv...)
+	}
+}
+
+func (s *sampledStructuredLogger) WarnKV(ctx context.Context, msg string, kv ...interface{}) {
+	s.structured.WarnKV(ctx, msg, kv...)
+}
+
+func (s *sampledStructuredLogger) ErrorKV(ctx context.Context, msg string, kv ...interface{}) {
+	s.structured.ErrorKV(ctx, msg, kv...)
+}
+
+// Every returns a RateLimiter allowing one call per d, e.g.
+//
+//	var everyMinute = logger.Every(time.Minute)
+//
+//	if everyMinute.Allow() {
+//		log.Warn("cache is full")
+//	}
+func Every(d time.Duration) *RateLimiter {
+	return &RateLimiter{interval: d.Nanoseconds()}
+}
+
+type RateLimiter struct {
+	interval int64
+	next     atomic.Int64
+}
+
+// Allow returns true if d has passed since the last allowed call
+func (r *RateLimiter) Allow() bool {
+	now := time.Now().UnixNano()
+	next := r.next.Load()
+	if now < next {
+		return false
+	}
+	return r.next.CompareAndSwap(next, now+r.interval)
+}
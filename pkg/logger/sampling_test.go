@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampled(t *testing.T) {
+	l, logs := newObservedLogger()
+	sampled := Sampled(l, SamplingConfig{Initial: 2, Thereafter: 3, Tick: time.Minute})
+	assert.Same(t, sampled, Sampled(sampled, DefaultSampling))
+
+	for i := 0; i < 8; i++ {
+		sampled.Info("request %d", i)
+		sampled.Warn("warn %d", i)
+	}
+	sampled.Info("another")
+
+	var infos, warns int
+	for _, entry := range logs.All() {
+		if entry.Level == InfoLevel {
+			infos++
+		} else {
+			warns++
+		}
+	}
+	// 1st, 2nd, 5th, 8th of "request %d", plus "another"
+	assert.Equal(t, 5, infos)
+	assert.Equal(t, 8, warns)
+}
+
+func TestSampledStructured(t *testing.T) {
+	l, logs := newObservedLevelLogger(InfoLevel)
+	sampled, isStructured := Sampled(l, SamplingConfig{Initial: 1, Tick: time.Minute}).(StructuredLogger)
+	assert.True(t, isStructured)
+	_, found := GetLevelController(sampled)
+	assert.True(t, found)
+
+	// loggers derived by With share the sampling
+	sampled.WithFields(String("request", "1")).InfoKV(context.Background(), "handled")
+	sampled.WithFields(String("request", "2")).InfoKV(context.Background(), "handled")
+	sampled.Named("authz").Info("denied %s", "sub")
+	assert.Len(t, logs.All(), 2)
+	assert.EqualValues(t, "authz", logs.All()[1].LoggerName)
+}
+
+func TestEvery(t *testing.T) {
+	every := Every(50 * time.Millisecond)
+	assert.True(t, every.Allow())
+	assert.False(t, every.Allow())
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, every.Allow())
+}
@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ slog.Handler = &zapSlogHandler{}
+)
+
+// NewSlogHandler returns a slog.Handler writing to the zap core of l, so
+// libraries logging via log/slog share the same sinks and encoding.
+// context values (ctxtags, request id, trace ids) are added to each record.
+func NewSlogHandler(l Logger) slog.Handler {
+	return &zapSlogHandler{core: GetUnderlyingZapLoggerOrDie(l).Core()}
+}
+
+// NewSlog is a shortcut of slog.New(NewSlogHandler(l))
+func NewSlog(l Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(l))
+}
+
+type zapSlogHandler struct {
+	core zapcore.Core
+	// groups opened by WithGroup, the innermost is the last one
+	groups []slogGroup
+}
+
+type slogGroup struct {
+	name   string
+	fields []zapcore.Field
+}
+
+func (z *zapSlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return z.core.Enabled(slogToZapLevel(level))
+}
+
+func (z *zapSlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := zapcore.Entry{
+		Level:   slogToZapLevel(record.Level),
+		Time:    record.Time,
+		Message: record.Message,
+	}
+	checked := z.core.Check(entry, nil)
+	if checked == nil {
+		return nil
+	}
+	var recordFields []zapcore.Field
+	record.Attrs(func(attr slog.Attr) bool {
+		recordFields = appendAttrFields(recordFields, attr)
+		return true
+	})
+	// nest record attrs into the open groups, context fields stay at top level
+	for i := len(z.groups) - 1; i >= 0; i-- {
+		groupFields := append(append([]zapcore.Field{}, z.groups[i].fields...), recordFields...)
+		recordFields = []zapcore.Field{zap.Object(z.groups[i].name, fieldsMarshaler(groupFields))}
+	}
+	checked.Write(append(contextFields(ctx), recordFields...)...)
+	return nil
+}
+
+func (z *zapSlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []zapcore.Field
+	for _, attr := range attrs {
+		fields = appendAttrFields(fields, attr)
+	}
+	if len(z.groups) == 0 {
+		return &zapSlogHandler{core: z.core.With(fields)}
+	}
+	groups := append([]slogGroup{}, z.groups...)
+	last := &groups[len(groups)-1]
+	last.fields = append(append([]zapcore.Field{}, last.fields...), fields...)
+	return &zapSlogHandler{core: z.core, groups: groups}
+}
+
+func (z *zapSlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return z
+	}
+	groups := append(append([]slogGroup{}, z.groups...), slogGroup{name: name})
+	return &zapSlogHandler{core: z.core, groups: groups}
+}
+
+func appendAttrFields(fields []zapcore.Field, attr slog.Attr) []zapcore.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(attr.Key, attr.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(attr.Key, attr.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(attr.Key, attr.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(attr.Key, attr.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(attr.Key, attr.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(attr.Key, attr.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(attr.Key, attr.Value.Time()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		if attr.Key == "" {
+			// groups without key are inlined
+			for _, groupAttr := range group {
+				fields = appendAttrFields(fields, groupAttr)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(attr.Key, groupMarshaler(group)))
+	}
+	if err, isErr := attr.Value.Any().(error); isErr {
+		return append(fields, zap.NamedError(attr.Key, err))
+	}
+	return append(fields, zap.Any(attr.Key, attr.Value.Any()))
+}
+
+type groupMarshaler []slog.Attr
+
+func (g groupMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, attr := range g {
+		fields = appendAttrFields(fields, attr)
+	}
+	return fieldsMarshaler(fields).MarshalLogObject(enc)
+}
+
+type fieldsMarshaler []zapcore.Field
+
+func (f fieldsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, field := range f {
+		field.AddTo(enc)
+	}
+	return nil
+}
+
+func slogToZapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+func zapToSlogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level < zapcore.InfoLevel:
+		return slog.LevelDebug
+	case level < zapcore.WarnLevel:
+		return slog.LevelInfo
+	case level < zapcore.ErrorLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ Logger           = &slogLogger{}
+	_ StructuredLogger = &slogLogger{}
+	_ zapcore.Core     = &slogCore{}
+)
+
+// NewSlogLogger returns a Logger writing to any slog.Handler, so it can be used
+// wherever a Logger is expected (e.g. service.WithLogger). context values
+// (ctxtags, request id, trace ids) are added to the records of the x/KV methods.
+func NewSlogLogger(h slog.Handler) *slogLogger {
+	_, contextAware := h.(*zapSlogHandler)
+	return &slogLogger{
+		handler:      h,
+		contextAware: contextAware,
+	}
+}
+
+type slogLogger struct {
+	handler slog.Handler
+	// contextAware is true when handler already extracts values from context
+	contextAware bool
+}
+
+func (s *slogLogger) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if !s.handler.Enabled(ctx, level) {
+		return
+	}
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	if !s.contextAware {
+		record.AddAttrs(fieldsToAttrs(contextFields(ctx))...)
+	}
+	record.AddAttrs(attrs...)
+	s.handler.Handle(ctx, record)
+}
+
+func (s *slogLogger) logKV(ctx context.Context, level slog.Level, msg string, kv ...interface{}) {
+	var attrs []slog.Attr
+	for len(kv) > 0 {
+		switch v := kv[0].(type) {
+		case Field:
+			attrs = append(attrs, fieldsToAttrs([]zapcore.Field{v})...)
+			kv = kv[1:]
+		case slog.Attr:
+			attrs = append(attrs, v)
+			kv = kv[1:]
+		case string:
+			if len(kv) == 1 {
+				attrs = append(attrs, slog.Any("!BADKEY", v))
+				kv = kv[1:]
+				continue
+			}
+			attrs = append(attrs, slog.Any(v, kv[1]))
+			kv = kv[2:]
+		default:
+			attrs = append(attrs, slog.Any("!BADKEY", v))
+			kv = kv[1:]
+		}
+	}
+	s.log(ctx, level, msg, attrs...)
+}
+
+func (s *slogLogger) Debug(fmtStr string, values ...interface{}) {
+	s.log(context.Background(), slog.LevelDebug, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Info(fmtStr string, values ...interface{}) {
+	s.log(context.Background(), slog.LevelInfo, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Warn(fmtStr string, values ...interface{}) {
+	s.log(context.Background(), slog.LevelWarn, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Error(fmtStr string, values ...interface{}) {
+	s.log(context.Background(), slog.LevelError, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Fatal(fmtStr string, values ...interface{}) {
+	fmt.Printf("[FATAL] %s", fmt.Sprintf(fmtStr, values...))
+	s.log(context.Background(), slog.LevelError, fmt.Sprintf(fmtStr, values...))
+	os.Exit(1)
+}
+
+func (s *slogLogger) Debugx(ctx context.Context, fmtStr string, values ...interface{}) {
+	s.log(ctx, slog.LevelDebug, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Infox(ctx context.Context, fmtStr string, values ...interface{}) {
+	s.log(ctx, slog.LevelInfo, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Warnx(ctx context.Context, fmtStr string, values ...interface{}) {
+	s.log(ctx, slog.LevelWarn, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) Errorx(ctx context.Context, fmtStr string, values ...interface{}) {
+	s.log(ctx, slog.LevelError, fmt.Sprintf(fmtStr, values...))
+}
+
+func (s *slogLogger) WithFields(fields ...Field) StructuredLogger {
+	return &slogLogger{
+		handler:      s.handler.WithAttrs(fieldsToAttrs(fields)),
+		contextAware: s.contextAware,
+	}
+}
+
+func (s *slogLogger) Named(component string) StructuredLogger {
+	return s.WithFields(String("logger", component))
+}
+
+func (s *slogLogger) DebugKV(ctx context.Context, msg string, kv ...interface{}) {
+	s.logKV(ctx, slog.LevelDebug, msg, kv...)
+}
+
+func (s *slogLogger) InfoKV(ctx context.Context, msg string, kv ...interface{}) {
+	s.logKV(ctx, slog.LevelInfo, msg, kv...)
+}
+
+func (s *slogLogger) WarnKV(ctx context.Context, msg string, kv ...interface{}) {
+	s.logKV(ctx, slog.LevelWarn, msg, kv...)
+}
+
+func (s *slogLogger) ErrorKV(ctx context.Context, msg string, kv ...interface{}) {
+	s.logKV(ctx, slog.LevelError, msg, kv...)
+}
+
+// zapLogger allows zap based middlewares (e.g. grpc_zap) to write to the handler
+func (s *slogLogger) zapLogger() *zap.Logger {
+	return zap.New(&slogCore{handler: s.handler})
+}
+
+// slogCore is a zapcore.Core writing to a slog.Handler
+type slogCore struct {
+	handler slog.Handler
+}
+
+func (s *slogCore) Enabled(level zapcore.Level) bool {
+	return s.handler.Enabled(context.Background(), zapToSlogLevel(level))
+}
+
+func (s *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &slogCore{handler: s.handler.WithAttrs(fieldsToAttrs(fields))}
+}
+
+func (s *slogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if s.Enabled(entry.Level) {
+		return checked.AddCore(entry, s)
+	}
+	return checked
+}
+
+func (s *slogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	record := slog.NewRecord(entry.Time, zapToSlogLevel(entry.Level), entry.Message, 0)
+	record.AddAttrs(fieldsToAttrs(fields)...)
+	return s.handler.Handle(context.Background(), record)
+}
+
+func (s *slogCore) Sync() error {
+	return nil
+}
+
+// fieldsToAttrs converts zap fields to slog attrs, keeping their order
+func fieldsToAttrs(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		for key, value := range enc.Fields {
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+	return attrs
+}
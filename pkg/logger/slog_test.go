@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sdinsure/agent/pkg/logger/ctxfields"
+)
+
+func TestSlogHandlerWritesToZapCore(t *testing.T) {
+	l, logs := newObservedLogger()
+
+	ctx := ctxfields.WithRequestId(context.Background(), "req-1")
+	NewSlog(l).With("component", "lib").WithGroup("http").InfoContext(ctx, "hello", "status", 200)
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.EqualValues(t, "hello", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.EqualValues(t, "lib", fields["component"])
+	assert.EqualValues(t, "req-1", fields["request_id"])
+	assert.EqualValues(t, map[string]interface{}{"status": int64(200)}, fields["http"])
+}
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewSlogLogger(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ctx := ctxfields.WithRequestId(context.Background(), "req-1")
+	l.WithFields(String("component", "test")).InfoKV(ctx, "hello", "count", 3)
+
+	record := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.EqualValues(t, "hello", record["msg"])
+	assert.EqualValues(t, "test", record["component"])
+	assert.EqualValues(t, 3, record["count"])
+	assert.EqualValues(t, "req-1", record["request_id"])
+
+	// zap based middlewares can write to the slog handler
+	buf.Reset()
+	GetUnderlyingZapLoggerOrDie(l).Info("from zap")
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.EqualValues(t, "from zap", record["msg"])
+}
@@ -2,10 +2,20 @@ package logger
 
 import "go.uber.org/zap"
 
+type zapLoggerGetter interface {
+	zapLogger() *zap.Logger
+}
+
+func (l *loggerImpl) zapLogger() *zap.Logger {
+	return l.Logger
+}
+
+// GetUnderlyingZapLoggerOrDie returns the zap logger of loggers created by
+// NewLogger or NewSlogLogger, and panics for other implementations
 func GetUnderlyingZapLoggerOrDie(l Logger) *zap.Logger {
-	_, isLoggerImpl := l.(*loggerImpl)
-	if !isLoggerImpl {
+	getter, hasZapLogger := l.(zapLoggerGetter)
+	if !hasZapLogger {
 		panic("not loggerImpl type")
 	}
-	return l.(*loggerImpl).Logger
+	return getter.zapLogger()
 }
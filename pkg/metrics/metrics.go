@@ -22,6 +22,11 @@ type Gauge interface {
 	Set(ctx context.Context, v float64, labels ...string)
 }
 
+// UpDownCounter tracks values going up and down, e.g. in-flight requests
+type UpDownCounter interface {
+	Add(ctx context.Context, delta int64, labels ...string)
+}
+
 type Histogram interface {
 	Observe(ctx context.Context, v time.Duration, labels ...string)
 }
@@ -177,6 +182,32 @@ func (g *GaugeVec) Set(ctx context.Context, v float64, labelValues ...string) {
 	})
 }
 
+var (
+	_ UpDownCounter = &UpDownCounterVec{}
+)
+
+// UpDownCounterVec is exported as a gauge, and unlike GaugeVec keeps a value
+// per label values
+type UpDownCounterVec struct {
+	v          otelmetric.Int64UpDownCounter
+	labelNames []string
+}
+
+func (c *Client) NewUpDownCounterVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelNames ...string) *UpDownCounterVec {
+	v, err := c.pkgMeter.Int64UpDownCounter(normalizedNames(ns, ss, name))
+	fatalIfNotNil(err)
+
+	return &UpDownCounterVec{
+		v:          v,
+		labelNames: labelNames,
+	}
+}
+
+// Add adds delta to labels.
+func (u *UpDownCounterVec) Add(ctx context.Context, delta int64, labelValues ...string) {
+	u.v.Add(ctx, delta, otelmetric.WithAttributes((makeAttributes(u.labelNames, labelValues))...))
+}
+
 var (
 	_ Histogram = &TimeDurationHistogramVec{}
 )
@@ -236,6 +267,36 @@ func (t *ValueHistogramVec) Observe(ctx context.Context, v int64, labelValues ..
 	t.v.Record(ctx, float64(v), otelmetric.WithAttributes((makeAttributes(t.labelNames, labelValues))...))
 }
 
+var (
+	_ ValueHistogram = &ByteSizeHistogramVec{}
+)
+
+type ByteSizeHistogramVec struct {
+	v          otelmetric.Float64Histogram
+	labelNames []string
+}
+
+// NewByteSizeHistogramVec records sizes from 64B to 16MB, e.g. of http bodies
+func (c *Client) NewByteSizeHistogramVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelNames ...string) *ByteSizeHistogramVec {
+	v, err := c.pkgMeter.Float64Histogram(
+		normalizedNames(ns, ss, name),
+		otelmetric.WithExplicitBucketBoundaries(
+			prom.ExponentialBuckets(64, 4, 10)...,
+		),
+		otelmetric.WithUnit("By"),
+	)
+	fatalIfNotNil(err)
+
+	return &ByteSizeHistogramVec{
+		v:          v,
+		labelNames: labelNames,
+	}
+}
+
+func (b *ByteSizeHistogramVec) Observe(ctx context.Context, v int64, labelValues ...string) {
+	b.v.Record(ctx, float64(v), otelmetric.WithAttributes((makeAttributes(b.labelNames, labelValues))...))
+}
+
 var defaultClient *Client
 var initOnce sync.Once
 
@@ -258,6 +319,10 @@ func NewGaugeVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelN
 	return GetDefaultClient().NewGaugeVec(ns, ss, name, labelNames...)
 }
 
+func NewUpDownCounterVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelNames ...string) *UpDownCounterVec {
+	return GetDefaultClient().NewUpDownCounterVec(ns, ss, name, labelNames...)
+}
+
 func NewTimeDurationHistogramVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelNames ...string) *TimeDurationHistogramVec {
 	return GetDefaultClient().NewTimeDurationHistogramVec(ns, ss, name, labelNames...)
 }
@@ -265,3 +330,7 @@ func NewTimeDurationHistogramVec(ns TypeNamespace, ss TypeSubsystem, name TypeMe
 func NewValueHistogramVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelNames ...string) *ValueHistogramVec {
 	return GetDefaultClient().NewValueHistogramVec(ns, ss, name, labelNames...)
 }
+
+func NewByteSizeHistogramVec(ns TypeNamespace, ss TypeSubsystem, name TypeMetricName, labelNames ...string) *ByteSizeHistogramVec {
+	return GetDefaultClient().NewByteSizeHistogramVec(ns, ss, name, labelNames...)
+}
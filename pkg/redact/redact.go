@@ -0,0 +1,260 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const Mask = "[REDACTED]"
+
+var (
+	// DefaultHeaders are masked by every Redactor
+	DefaultHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	// DefaultFields are masked in json bodies and extracted fields by every Redactor
+	DefaultFields = []string{"password", "secret", "token", "access_token", "refresh_token", "id_token", "client_secret"}
+
+	DefaultMaxBodyBytes = 4 * 1024
+)
+
+type RedactorOptioner interface {
+	apply(*RedactorOptions)
+}
+
+type RedactorOptions struct {
+	fields       []string
+	headers      []string
+	maxBodyBytes int
+}
+
+type fields struct {
+	paths []string
+}
+
+func (f fields) apply(o *RedactorOptions) {
+	o.fields = append(o.fields, f.paths...)
+}
+
+// WithFields masks fields by full name (e.g. "example.api.LoginRequest.password"),
+// by dotted path from the root message (e.g. "credential.secret"), or by field
+// name at any depth (e.g. "ssn"). fields with the debug_redact option are
+// always masked.
+func WithFields(paths ...string) fields {
+	return fields{paths: paths}
+}
+
+type headers struct {
+	names []string
+}
+
+func (h headers) apply(o *RedactorOptions) {
+	o.headers = append(o.headers, h.names...)
+}
+
+// WithHeaders masks headers in addition to DefaultHeaders
+func WithHeaders(names ...string) headers {
+	return headers{names: names}
+}
+
+type maxBodyBytes struct {
+	n int
+}
+
+func (m maxBodyBytes) apply(o *RedactorOptions) {
+	o.maxBodyBytes = m.n
+}
+
+// WithMaxBodyBytes caps logged bodies, 0 drops them entirely
+func WithMaxBodyBytes(n int) maxBodyBytes {
+	return maxBodyBytes{n: n}
+}
+
+func newOptions(opts ...RedactorOptioner) *RedactorOptions {
+	o := &RedactorOptions{
+		fields:       append([]string{}, DefaultFields...),
+		headers:      append([]string{}, DefaultHeaders...),
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	return o
+}
+
+func NewRedactor(opts ...RedactorOptioner) *Redactor {
+	o := newOptions(opts...)
+	r := &Redactor{
+		fields:       make(map[string]struct{}),
+		headers:      make(map[string]struct{}),
+		maxBodyBytes: o.maxBodyBytes,
+	}
+	for _, field := range o.fields {
+		r.fields[normalizeName(field)] = struct{}{}
+	}
+	for _, header := range o.headers {
+		r.headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	return r
+}
+
+// Redactor masks sensitive values before they are logged
+type Redactor struct {
+	fields       map[string]struct{}
+	headers      map[string]struct{}
+	maxBodyBytes int
+}
+
+// jsonKeyValue matches "key": value, for bodies which are not valid json (e.g. truncated)
+var jsonKeyValue = regexp.MustCompile(`"((?:[^"\\]|\\.){1,128})"\s*:\s*("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+
+// normalizeName makes snake_case, kebab-case and lowerCamelCase names equal,
+// as grpc-gateway renders fields in lowerCamelCase
+func normalizeName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
+
+func (r *Redactor) isSensitiveName(name string) bool {
+	_, found := r.fields[normalizeName(name)]
+	return found
+}
+
+func (r *Redactor) isSensitiveField(fd protoreflect.FieldDescriptor, path string) bool {
+	if opts, isFieldOptions := fd.Options().(*descriptorpb.FieldOptions); isFieldOptions && opts.GetDebugRedact() {
+		return true
+	}
+	return r.isSensitiveName(string(fd.FullName())) ||
+		r.isSensitiveName(path) ||
+		r.isSensitiveName(string(fd.Name())) ||
+		r.isSensitiveName(fd.JSONName())
+}
+
+// Message returns a copy of m with sensitive fields masked. string fields are
+// set to Mask, other kinds are cleared.
+func (r *Redactor) Message(m proto.Message) proto.Message {
+	if m == nil {
+		return nil
+	}
+	clone := proto.Clone(m)
+	r.redactMessage(clone.ProtoReflect(), "")
+	return clone
+}
+
+func (r *Redactor) redactMessage(m protoreflect.Message, prefix string) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := string(fd.Name())
+		if len(prefix) > 0 {
+			path = prefix + "." + path
+		}
+		if r.isSensitiveField(fd, path) {
+			if fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+				m.Set(fd, protoreflect.ValueOfString(Mask))
+			} else {
+				m.Clear(fd)
+			}
+			return true
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				r.redactMessage(list.Get(i).Message(), path)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				r.redactMessage(mv.Message(), path)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			r.redactMessage(v.Message(), path)
+		}
+		return true
+	})
+}
+
+// Header returns a copy of h with sensitive headers masked
+func (r *Redactor) Header(h http.Header) http.Header {
+	clone := h.Clone()
+	for name := range clone {
+		if _, found := r.headers[http.CanonicalHeaderKey(name)]; found {
+			clone[name] = []string{Mask}
+		}
+	}
+	return clone
+}
+
+// Fields masks sensitive keys of fields extracted from requests (e.g. by
+// grpc_ctxtags) in place, and truncates long string values
+func (r *Redactor) Fields(fields map[string]interface{}) map[string]interface{} {
+	for key, value := range fields {
+		name := key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			name = key[i+1:]
+		}
+		if r.isSensitiveName(key) || r.isSensitiveName(name) {
+			fields[key] = Mask
+			continue
+		}
+		if s, isString := value.(string); isString {
+			fields[key] = r.truncate(s)
+		}
+	}
+	return fields
+}
+
+// Body returns body for logging, json bodies have sensitive keys masked, and
+// the result is capped by WithMaxBodyBytes
+func (r *Redactor) Body(body []byte) string {
+	if r.maxBodyBytes <= 0 || len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if redacted, err := json.Marshal(r.redactJSON(v)); err == nil {
+			return r.truncate(string(redacted))
+		}
+	}
+	text := jsonKeyValue.ReplaceAllStringFunc(string(body), func(kv string) string {
+		matched := jsonKeyValue.FindStringSubmatch(kv)
+		if !r.isSensitiveName(matched[1]) {
+			return kv
+		}
+		return kv[:len(kv)-len(matched[2])] + `"` + Mask + `"`
+	})
+	return r.truncate(text)
+}
+
+func (r *Redactor) redactJSON(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for key, value := range vv {
+			if r.isSensitiveName(key) {
+				vv[key] = Mask
+				continue
+			}
+			vv[key] = r.redactJSON(value)
+		}
+	case []interface{}:
+		for i := range vv {
+			vv[i] = r.redactJSON(vv[i])
+		}
+	}
+	return v
+}
+
+func (r *Redactor) truncate(s string) string {
+	if len(s) <= r.maxBodyBytes {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d bytes truncated)", s[:r.maxBodyBytes], len(s)-r.maxBodyBytes)
+}
+
+// MaxBodyBytes is the cap of logged bodies
+func (r *Redactor) MaxBodyBytes() int {
+	return r.maxBodyBytes
+}
@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// newLoginRequest returns message LoginRequest { string user = 1; string pin = 2 [debug_redact = true]; string ssn = 3; }
+func newLoginRequest(t *testing.T) protoreflect.Message {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("login.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("LoginRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("user"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				{Name: proto.String("pin"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Options: &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}},
+				{Name: proto.String("ssn"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+	}, nil)
+	assert.NoError(t, err)
+	msg := dynamicpb.NewMessage(file.Messages().ByName("LoginRequest"))
+	for name, value := range map[string]string{"user": "alice", "pin": "1234", "ssn": "123-45-6789"} {
+		msg.Set(msg.Descriptor().Fields().ByName(protoreflect.Name(name)), protoreflect.ValueOfString(value))
+	}
+	return msg
+}
+
+func TestMessage(t *testing.T) {
+	msg := newLoginRequest(t)
+	redacted := NewRedactor(WithFields("test.LoginRequest.ssn")).Message(msg.Interface()).ProtoReflect()
+
+	fields := msg.Descriptor().Fields()
+	assert.Equal(t, "alice", redacted.Get(fields.ByName("user")).String())
+	assert.Equal(t, Mask, redacted.Get(fields.ByName("pin")).String())
+	assert.Equal(t, Mask, redacted.Get(fields.ByName("ssn")).String())
+	// original is untouched
+	assert.Equal(t, "1234", msg.Get(fields.ByName("pin")).String())
+}
+
+func TestHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("X-Api-Key", "key")
+	h.Set("Accept", "application/json")
+
+	redacted := NewRedactor(WithHeaders("x-api-key")).Header(h)
+	assert.Equal(t, Mask, redacted.Get("Authorization"))
+	assert.Equal(t, Mask, redacted.Get("X-Api-Key"))
+	assert.Equal(t, "application/json", redacted.Get("Accept"))
+	assert.Equal(t, "Bearer abc", h.Get("Authorization"))
+}
+
+func TestBody(t *testing.T) {
+	r := NewRedactor(WithFields("ssn"), WithMaxBodyBytes(96))
+
+	assert.Equal(t, `{"user":{"accessToken":"[REDACTED]","name":"bob","ssn":"[REDACTED]"}}`,
+		r.Body([]byte(`{"user":{"name":"bob","accessToken":"abc","ssn":"123"}}`)))
+
+	// truncated json is masked by key
+	body := `{"password": "p@ss", "items": [` + strings.Repeat(`"x",`, 50)
+	redacted := r.Body([]byte(body))
+	assert.True(t, strings.HasPrefix(redacted, `{"password": "[REDACTED]", "items": [`), redacted)
+	assert.Contains(t, redacted, "bytes truncated")
+
+	assert.Empty(t, NewRedactor(WithMaxBodyBytes(0)).Body([]byte(body)))
+}
+
+func TestFields(t *testing.T) {
+	fields := NewRedactor().Fields(map[string]interface{}{
+		"grpc.request.user":     "alice",
+		"grpc.request.password": "secret",
+	})
+	assert.Equal(t, "alice", fields["grpc.request.user"])
+	assert.Equal(t, Mask, fields["grpc.request.password"])
+}
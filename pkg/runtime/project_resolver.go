@@ -37,7 +37,7 @@ type ProjectInfor interface {
 
 func NewProjectResolver(log logger.Logger, projectGetter ProjectGetter) *projectResolver {
 	return &projectResolver{
-		log:           log,
+		log:           logger.Sampled(log, logger.DefaultSampling),
 		projectGetter: projectGetter,
 	}
 }
@@ -3,7 +3,7 @@ package runtime
 import (
 	"context"
 
-	"github.com/google/uuid"
+	sdinsureruntime "github.com/sdinsure/agent/pkg/grpc/server/runtime"
 )
 
 type RequestIdentityResolver interface {
@@ -14,16 +14,15 @@ type RequestIdentityResolver interface {
 	RequestID(context.Context) (TypeRequestID, bool)
 }
 
-type (
-	requestIdKey struct{}
-)
-
 type TypeRequestID string
 
 func (t TypeRequestID) String() string {
 	return string(t)
 }
 
+// UUIDRequestIdentityResolver keeps the request id accepted from the client
+// and generates an uuid otherwise. the id is shared with
+// sdinsureruntime.RequestId, so both return the same value.
 type UUIDRequestIdentityResolver struct{}
 
 var (
@@ -31,11 +30,10 @@ var (
 )
 
 func (u *UUIDRequestIdentityResolver) WithRequestID(ctx context.Context) context.Context {
-	return context.WithValue(ctx, requestIdKey{}, TypeRequestID(uuid.NewString()))
+	return sdinsureruntime.WithRequestId(ctx)
 }
 
 func (u *UUIDRequestIdentityResolver) RequestID(ctx context.Context) (TypeRequestID, bool) {
-	info := ctx.Value(requestIdKey{})
-	infov, castable := info.(TypeRequestID)
-	return infov, castable
+	reqId, found := sdinsureruntime.RequestId(ctx)
+	return TypeRequestID(reqId), found
 }
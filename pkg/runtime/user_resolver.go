@@ -34,7 +34,7 @@ type (
 
 func NewIdentityResolver(log logger.Logger, userGetter UserGetter) *IdentityResolver {
 	return &IdentityResolver{
-		log:        log,
+		log:        logger.Sampled(log, logger.DefaultSampling),
 		userGetter: userGetter,
 	}
 }